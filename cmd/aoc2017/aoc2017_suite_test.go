@@ -0,0 +1,13 @@
+package main
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestAoc2017(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Aoc2017 Suite")
+}
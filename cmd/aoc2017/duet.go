@@ -0,0 +1,85 @@
+package main
+
+import (
+	"adventofcode2017"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+)
+
+const duetUsage = `usage: aoc2017 duet [-network tcp|unix] (--listen|--connect) address program
+
+runs one half of a Day 18 duet, sending and receiving values over a
+socket, and prints how many values it sent. the listening side is
+program 0 and the connecting side program 1, so start the listener
+first. the program is a file of duet instructions, or "-" for standard
+input.
+
+  -network  "tcp" (the default) or "unix"
+  -listen   wait for the other half on address
+  -connect  connect to the other half at address
+`
+
+func runDuet(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("duet", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, duetUsage) }
+	network := flags.String("network", "tcp", "")
+	listen := flags.String("listen", "", "")
+	connect := flags.String("connect", "", "")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if (*listen == "") == (*connect == "") {
+		fmt.Fprintf(stderr, "aoc2017 duet: exactly one of --listen and --connect is needed\n")
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	program, err := readDuetProgram(flags.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "aoc2017 duet: %s\n", err)
+		return 1
+	}
+
+	var id int
+	var transport adventofcode2017.DuetTransport
+	if *listen != "" {
+		var listener net.Listener
+		if listener, err = net.Listen(*network, *listen); err == nil {
+			transport, err = adventofcode2017.AcceptDuet(listener)
+		}
+	} else {
+		id = 1
+		transport, err = adventofcode2017.DialDuet(*network, *connect)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "aoc2017 duet: %s\n", err)
+		return 1
+	}
+
+	s := adventofcode2017.RunDuet(transport, id, program)
+	fmt.Fprintf(stdout, "program %d sent %d %s\n", id, s.SentCount(), plural(s.SentCount(), "value", "values"))
+	if s.Deadlocked() {
+		fmt.Fprintf(stdout, "program %d deadlocked waiting on rcv\n", id)
+	}
+	if err := s.Fault(); err != nil {
+		fmt.Fprintf(stderr, "aoc2017 duet: program %d halted: %s\n", id, err)
+		return 1
+	}
+	return 0
+}
+
+func readDuetProgram(name string, stdin io.Reader) (string, error) {
+	if name == "-" {
+		data, err := ioutil.ReadAll(stdin)
+		return string(data), err
+	}
+	data, err := ioutil.ReadFile(name)
+	return string(data), err
+}
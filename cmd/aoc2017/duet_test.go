@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("aoc2017", func() {
+	Describe("duet", func() {
+		var dir, socket string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "aoc2017")
+			Expect(err).NotTo(HaveOccurred())
+			socket = filepath.Join(dir, "duet.sock")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		// runs both halves as they'd be run from two shells, returning
+		// the output of the listening half and then the connecting half.
+		duet := func(program string) (string, string) {
+			path := filepath.Join(dir, "duet.txt")
+			Expect(ioutil.WriteFile(path, []byte(program), 0644)).To(Succeed())
+
+			var listenOut, listenErr bytes.Buffer
+			done := make(chan int)
+			go func() {
+				done <- run([]string{"duet", "-network", "unix", "--listen", socket, path}, nil, &listenOut, &listenErr)
+			}()
+			Eventually(func() error { _, err := os.Stat(socket); return err }).Should(Succeed())
+
+			var connectOut, connectErr bytes.Buffer
+			Expect(run([]string{"duet", "-network", "unix", "--connect", socket, "-"}, strings.NewReader(program), &connectOut, &connectErr)).To(Equal(0))
+			Expect(<-done).To(Equal(0))
+			Expect(listenErr.String()).To(BeEmpty())
+			Expect(connectErr.String()).To(BeEmpty())
+			return listenOut.String(), connectOut.String()
+		}
+
+		It("runs the two halves of a duet", func() {
+			program0, program1 := duet(heredoc.Doc(`
+				snd p
+				add p 10
+				snd p
+				rcv a
+				rcv b
+			`))
+			Expect(program0).To(Equal("program 0 sent 2 values\n"))
+			Expect(program1).To(Equal("program 1 sent 2 values\n"))
+		})
+
+		It("reports deadlock", func() {
+			program0, program1 := duet("rcv a\nsnd a\n")
+			Expect(program0).To(Equal("program 0 sent 0 values\nprogram 0 deadlocked waiting on rcv\n"))
+			Expect(program1).To(Equal("program 1 sent 0 values\nprogram 1 deadlocked waiting on rcv\n"))
+		})
+
+		It("needs exactly one of --listen and --connect", func() {
+			var stdout, stderr bytes.Buffer
+			Expect(run([]string{"duet", "day18.txt"}, nil, &stdout, &stderr)).To(Equal(2))
+			Expect(stderr.String()).To(Equal("aoc2017 duet: exactly one of --listen and --connect is needed\n"))
+
+			stderr.Reset()
+			Expect(run([]string{"duet", "--listen", ":0", "--connect", ":0", "day18.txt"}, nil, &stdout, &stderr)).To(Equal(2))
+			Expect(stderr.String()).To(ContainSubstring("exactly one"))
+		})
+
+		It("reports a peer it can't reach", func() {
+			var stdout, stderr bytes.Buffer
+			Expect(run([]string{"duet", "-network", "unix", "--connect", socket, "-"}, strings.NewReader("snd 1\n"), &stdout, &stderr)).To(Equal(1))
+			Expect(stderr.String()).To(HavePrefix("aoc2017 duet: dial unix " + socket))
+			Expect(stdout.String()).To(BeEmpty())
+		})
+	})
+})
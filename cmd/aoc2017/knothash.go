@@ -0,0 +1,157 @@
+package main
+
+import (
+	"adventofcode2017/knothash"
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const knothashUsage = `usage: aoc2017 knothash [-c] [-s] [file|string ...]
+
+prints the knot hash of each file, like sha256sum. the file's bytes are
+the lengths, trailing newline and all. with no files, or for "-", reads
+standard input.
+
+  -s  hash the arguments themselves, as Day 10 does with its input
+  -c  read "<hash>  <file>" lines from the files and check each one
+`
+
+// "<hash>  <file>", or "<hash> *<file>" as sha256sum writes for binary mode.
+var checkLineRe = regexp.MustCompile(`^([0-9a-fA-F]{32}) [ *](.+)$`)
+
+func runKnothash(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("knothash", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, knothashUsage) }
+	check := flags.Bool("c", false, "")
+	literal := flags.Bool("s", false, "")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *check && *literal {
+		fmt.Fprintf(stderr, "aoc2017 knothash: -c and -s can't be used together\n")
+		return 2
+	}
+
+	if *literal {
+		for _, arg := range flags.Args() {
+			sum := knothash.Sum([]byte(arg))
+			fmt.Fprintf(stdout, "%s  %s\n", hex.EncodeToString(sum[:]), strconv.Quote(arg))
+		}
+		return 0
+	}
+
+	names := flags.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	status := 0
+	for _, name := range names {
+		var err error
+		if *check {
+			var ok bool
+			ok, err = checkKnothashes(name, stdin, stdout, stderr)
+			if !ok {
+				status = 1
+			}
+		} else {
+			var sum string
+			if sum, err = hashKnothashFile(name, stdin); err == nil {
+				fmt.Fprintf(stdout, "%s  %s\n", sum, name)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "aoc2017 knothash: %s\n", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+func openKnothashFile(name string, stdin io.Reader) (io.ReadCloser, error) {
+	if name == "-" {
+		return ioutil.NopCloser(stdin), nil
+	}
+	return os.Open(name)
+}
+
+// the hex knot hash of a file's contents.
+func hashKnothashFile(name string, stdin io.Reader) (string, error) {
+	file, err := openKnothashFile(name, stdin)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := knothash.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checks each file listed in a check file, reporting OK or FAILED, like
+// sha256sum -c. ok is whether every one matched.
+func checkKnothashes(name string, stdin io.Reader, stdout, stderr io.Writer) (ok bool, err error) {
+	list, err := openKnothashFile(name, stdin)
+	if err != nil {
+		return false, err
+	}
+	defer list.Close()
+
+	ok = true
+	mismatched, unreadable, malformed := 0, 0, 0
+	scanner := bufio.NewScanner(list)
+	for jline := 1; scanner.Scan(); jline++ {
+		match := checkLineRe.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			fmt.Fprintf(stderr, "aoc2017 knothash: %s: %d: improperly formatted knot hash line\n", name, jline)
+			malformed++
+			continue
+		}
+		sum, err := hashKnothashFile(match[2], stdin)
+		switch {
+		case err != nil:
+			fmt.Fprintf(stderr, "aoc2017 knothash: %s\n", err)
+			fmt.Fprintf(stdout, "%s: FAILED open or read\n", match[2])
+			unreadable++
+		case sum != strings.ToLower(match[1]):
+			fmt.Fprintf(stdout, "%s: FAILED\n", match[2])
+			mismatched++
+		default:
+			fmt.Fprintf(stdout, "%s: OK\n", match[2])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+
+	if malformed > 0 {
+		fmt.Fprintf(stderr, "aoc2017 knothash: WARNING: %d %s improperly formatted\n", malformed, plural(malformed, "line is", "lines are"))
+		ok = false
+	}
+	if unreadable > 0 {
+		fmt.Fprintf(stderr, "aoc2017 knothash: WARNING: %d listed %s could not be read\n", unreadable, plural(unreadable, "file", "files"))
+		ok = false
+	}
+	if mismatched > 0 {
+		fmt.Fprintf(stderr, "aoc2017 knothash: WARNING: %d computed %s did NOT match\n", mismatched, plural(mismatched, "checksum", "checksums"))
+		ok = false
+	}
+	return ok, nil
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("aoc2017", func() {
+	var stdout, stderr bytes.Buffer
+	var dir string
+
+	aoc2017 := func(stdin string, args ...string) int {
+		return run(args, strings.NewReader(stdin), &stdout, &stderr)
+	}
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(contents), 0644)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		stdout.Reset()
+		stderr.Reset()
+		var err error
+		dir, err = ioutil.TempDir("", "aoc2017")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("explains itself", func() {
+		Expect(aoc2017("")).To(Equal(2))
+		Expect(stderr.String()).To(ContainSubstring("knothash"))
+
+		Expect(aoc2017("", "sha256")).To(Equal(2))
+		Expect(stderr.String()).To(ContainSubstring(`unknown command "sha256"`))
+	})
+
+	Describe("knothash", func() {
+		It("hashes literal strings", func() {
+			Expect(aoc2017("", "knothash", "-s", "", "AoC 2017")).To(Equal(0))
+			Expect(stdout.String()).To(Equal(heredoc.Doc(`
+				a2582a3a0e66e6e86e3812dcb672a272  ""
+				33efeb34ea91902bb2f59c9920caa6cd  "AoC 2017"
+			`)))
+		})
+
+		It("hashes files", func() {
+			a := write("a.txt", "1,2,3")
+			b := write("b.txt", "1,2,4")
+			Expect(aoc2017("", "knothash", a, b)).To(Equal(0))
+			Expect(stdout.String()).To(Equal(
+				"3efbe78a8d82f29979031a4aa0b16a9d  " + a + "\n" +
+					"63960835bcdc130f0b66d7ff4f6a5a8e  " + b + "\n"))
+		})
+
+		It("hashes standard input", func() {
+			Expect(aoc2017("AoC 2017", "knothash")).To(Equal(0))
+			Expect(stdout.String()).To(Equal("33efeb34ea91902bb2f59c9920caa6cd  -\n"))
+
+			stdout.Reset()
+			Expect(aoc2017("AoC 2017", "knothash", "-")).To(Equal(0))
+			Expect(stdout.String()).To(Equal("33efeb34ea91902bb2f59c9920caa6cd  -\n"))
+		})
+
+		It("reports files it can't read, and carries on", func() {
+			a := write("a.txt", "1,2,3")
+			Expect(aoc2017("", "knothash", filepath.Join(dir, "missing"), a)).To(Equal(1))
+			Expect(stdout.String()).To(Equal("3efbe78a8d82f29979031a4aa0b16a9d  " + a + "\n"))
+			Expect(stderr.String()).To(ContainSubstring("missing"))
+		})
+
+		It("checks its own output", func() {
+			a := write("a.txt", "1,2,3")
+			b := write("b.txt", "1,2,4")
+			Expect(aoc2017("", "knothash", a, b)).To(Equal(0))
+			sums := write("sums", stdout.String())
+
+			stdout.Reset()
+			Expect(aoc2017("", "knothash", "-c", sums)).To(Equal(0))
+			Expect(stdout.String()).To(Equal(a + ": OK\n" + b + ": OK\n"))
+			Expect(stderr.String()).To(BeEmpty())
+		})
+
+		It("reports files that don't match, can't be read, or are listed badly", func() {
+			a := write("a.txt", "1,2,3")
+			b := write("b.txt", "1,2,5")
+			missing := filepath.Join(dir, "missing")
+			list := "3EFBE78A8D82F29979031A4AA0B16A9D *" + a + "\n" +
+				"63960835bcdc130f0b66d7ff4f6a5a8e  " + b + "\n" +
+				"63960835bcdc130f0b66d7ff4f6a5a8e  " + missing + "\n" +
+				"not a knot hash\n"
+
+			Expect(aoc2017(list, "knothash", "-c")).To(Equal(1))
+			Expect(stdout.String()).To(Equal(a + ": OK\n" + b + ": FAILED\n" + missing + ": FAILED open or read\n"))
+			Expect(stderr.String()).To(ContainSubstring("-: 4: improperly formatted knot hash line"))
+			Expect(stderr.String()).To(ContainSubstring("WARNING: 1 line is improperly formatted"))
+			Expect(stderr.String()).To(ContainSubstring("WARNING: 1 listed file could not be read"))
+			Expect(stderr.String()).To(ContainSubstring("WARNING: 1 computed checksum did NOT match"))
+		})
+
+		It("rejects bad flags", func() {
+			Expect(aoc2017("", "knothash", "-x")).To(Equal(2))
+			Expect(stderr.String()).To(ContainSubstring("usage: aoc2017 knothash"))
+
+			Expect(aoc2017("", "knothash", "-c", "-s", "foo")).To(Equal(2))
+		})
+	})
+})
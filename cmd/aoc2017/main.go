@@ -0,0 +1,34 @@
+// Command aoc2017 runs the parts of this Advent of Code that are useful
+// outside of it.
+//
+//	aoc2017 knothash [-c] [-s] [file|string ...]
+//	aoc2017 duet [-network tcp|unix] (--listen|--connect) address program
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+var subcommands = map[string]func(args []string, stdin io.Reader, stdout, stderr io.Writer) int{
+	"knothash": runKnothash,
+	"duet":     runDuet,
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintf(stderr, "usage: aoc2017 <command> [arguments]\n\ncommands:\n  knothash  print or check knot hashes\n  duet      run one half of a Day 18 duet over a socket\n")
+		return 2
+	}
+	subcommand, ok := subcommands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "aoc2017: unknown command %q\n", args[0])
+		return 2
+	}
+	return subcommand(args[1:], stdin, stdout, stderr)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
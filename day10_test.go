@@ -5,6 +5,8 @@ import (
 	"regexp"
 	"strconv"
 
+	"adventofcode2017/knothash"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -36,23 +38,16 @@ func (kh *KnotHash) hash(lengthsDescriptor string) int {
 	return int(kh.list[0]) * int(kh.list[1])
 }
 
-var SEQUENCE_SUFFIX = []byte{17, 31, 73, 47, 23}
-var HASH_ROUNDS = 64
-
+// the full Day 10 hash, over a fresh list the size of kh's every time.
 func (kh *KnotHash) fullHash(lengthsDescriptor string) string {
-	lengths := append([]byte(lengthsDescriptor), SEQUENCE_SUFFIX...)
-
-	for jround := 0; jround < HASH_ROUNDS; jround++ {
-		for j := 0; j < len(lengths); j++ {
-			kh.hashStep(int(lengths[j]))
-		}
+	options := knothash.DefaultOptions()
+	options.ListSize = len(kh.list)
+	h, err := knothash.NewWithOptions(options)
+	if err != nil {
+		panic(fmt.Sprintf("error: %s", err))
 	}
-
-	// make dense hash
-	denseHash := densify(kh.list)
-
-	// return hex
-	return hexify(denseHash)
+	h.Write([]byte(lengthsDescriptor))
+	return hexify(h.Sum(nil))
 }
 
 func (kh *KnotHash) hashStep(length int) {
@@ -150,6 +145,11 @@ var _ = Describe("Day10", func() {
 				Expect(NewKnotHash(256).fullHash("1,2,4")).
 					To(Equal("63960835bcdc130f0b66d7ff4f6a5a8e"))
 			})
+
+			It("gives the same answer when called twice", func() {
+				kh := NewKnotHash(256)
+				Expect(kh.fullHash("AoC 2017")).To(Equal(kh.fullHash("AoC 2017")))
+			})
 		})
 
 		Describe("hashStep", func() {
@@ -0,0 +1,296 @@
+package adventofcode2017
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DuetCpu struct {
+	id        int
+	registers map[byte]int
+	pc        int
+	incoming  chan int
+	outgoing  chan int
+	sentCount int
+	mulCount  int
+	profiler  *DuetCpuProfiler
+
+	transport      DuetTransport // nil means the incoming and outgoing channels
+	instructionSet DuetInstructionSet
+	program        []DuetInstruction // the program being run, for opcodes that inspect or modify it
+
+	numeric      *DuetNumericModel
+	bigRegisters map[byte]*big.Int // values too large for registers, unbounded model only
+	overflows    []DuetOverflow
+	fault        error
+	deadlocked   bool // gave up on a rcv that nothing answered
+}
+
+func NewDuetCpu(id int) *DuetCpu {
+	d := DuetCpu{registers: make(map[byte]int), incoming: make(chan int, 100), instructionSet: NewDuetInstructionSet()}
+	d.id = id
+	d.registers['p'] = d.id
+	return &d
+}
+
+func (s *DuetCpu) setOutgoing(outgoing chan int) {
+	s.outgoing = outgoing
+}
+
+func (s *DuetCpu) SentCount() int {
+	return s.sentCount
+}
+
+// the error that halted the cpu, if any.
+func (s *DuetCpu) Fault() error {
+	return s.fault
+}
+
+// whether the cpu stopped waiting on a rcv, either because the peer was
+// waiting too or because it had gone away.
+func (s *DuetCpu) Deadlocked() bool {
+	return s.deadlocked
+}
+
+func (s *DuetCpu) getRegister(name byte) int {
+	val, ok := s.registers[name]
+	if ok {
+		return val
+	}
+	s.registers[name] = 0
+	return 0
+}
+
+// the value of a register, which is zero until it is written.
+func (s *DuetCpu) Register(name byte) int {
+	return s.getRegister(name)
+}
+
+func (s *DuetCpu) SetRegister(name byte, value int) {
+	s.registers[name] = value
+	delete(s.bigRegisters, name)
+}
+
+// the value of an operand, either a literal or a register.
+func (s *DuetCpu) ValueOf(operand string) int {
+	return s.valueOf(operand)
+}
+
+// moves the pc by offset; 1 goes on to the next instruction.
+func (s *DuetCpu) Jump(offset int) {
+	s.pc += offset
+}
+
+func (s *DuetCpu) valueOf(thing string) int {
+	if val, err := strconv.Atoi(thing); err == nil {
+		return val
+	} else {
+		return s.getRegister(thing[0])
+	}
+}
+
+type DuetInstruction struct {
+	op   string
+	args []string
+}
+
+func parseDuetInstruction(line string) DuetInstruction {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return DuetInstruction{}
+	}
+	return DuetInstruction{op: fields[0], args: fields[1:]}
+}
+
+func parseDuetProgram(rawInstructions string) []DuetInstruction {
+	lines := strings.Split(rawInstructions, "\n")
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	program := make([]DuetInstruction, len(lines))
+	for j, line := range lines {
+		program[j] = parseDuetInstruction(line)
+	}
+	return program
+}
+
+func (i DuetInstruction) Op() string {
+	return i.op
+}
+
+func (i DuetInstruction) Args() []string {
+	return i.args
+}
+
+func (i DuetInstruction) String() string {
+	return strings.Join(append([]string{i.op}, i.args...), " ")
+}
+
+func (i DuetInstruction) isJump() bool {
+	return i.op == "jnz" || i.op == "jgz"
+}
+
+// the offset of a jump, if it is a literal.
+func (i DuetInstruction) jumpOffset() (int, bool) {
+	offset, err := strconv.Atoi(i.args[1])
+	return offset, err == nil
+}
+
+// an instruction the cpu can run. the handler does all of its work,
+// including moving the pc on with Jump.
+type DuetOpcode struct {
+	Name    string
+	Arity   int
+	Writes  bool // the first operand is a register that gets written
+	Handler func(s *DuetCpu, instruction DuetInstruction)
+
+	builtin bool // one of the stock days 18 and 23 opcodes, which optimizer natives assume
+}
+
+type DuetInstructionSet map[string]DuetOpcode // opcode name → opcode
+
+// the instruction set shared by days 18 and 23.
+func NewDuetInstructionSet() DuetInstructionSet {
+	is := make(DuetInstructionSet)
+	is.Register(DuetOpcode{Name: "snd", Arity: 1, builtin: true, Handler: duetSnd})
+	is.Register(DuetOpcode{Name: "rcv", Arity: 1, Writes: true, builtin: true, Handler: duetRcv})
+	for _, name := range []string{"set", "add", "sub", "mul", "mod"} {
+		is.Register(DuetOpcode{Name: name, Arity: 2, Writes: true, builtin: true, Handler: duetArithmetic})
+	}
+	for _, name := range []string{"jgz", "jnz"} {
+		is.Register(DuetOpcode{Name: name, Arity: 2, builtin: true, Handler: duetJump})
+	}
+	return is
+}
+
+// registering an existing name replaces it.
+func (is DuetInstructionSet) Register(opcode DuetOpcode) {
+	is[opcode.Name] = opcode
+}
+
+// adds an opcode to this cpu's instruction set, or replaces the opcode
+// of the same name.
+func (s *DuetCpu) RegisterOpcode(opcode DuetOpcode) {
+	s.instructionSet.Register(opcode)
+}
+
+func (s *DuetCpu) execInstruction(instruction string) {
+	s.exec(parseDuetInstruction(instruction))
+}
+
+// an unknown opcode, or the wrong number of operands, halts the cpu.
+func (s *DuetCpu) exec(instruction DuetInstruction) {
+	opcode, ok := s.instructionSet[instruction.op]
+	if !ok || len(instruction.args) != opcode.Arity {
+		s.halt(fmt.Errorf("unknown instruction %q", instruction.String()))
+		return
+	}
+	opcode.Handler(s, instruction)
+}
+
+func duetSnd(s *DuetCpu, instruction DuetInstruction) {
+	srcValue := s.valueOf(instruction.args[0])
+	if s.numeric != nil {
+		if value := s.bigValueOf(instruction.args[0]); !value.IsInt64() {
+			s.halt(fmt.Errorf("snd: `%s` at pc %d sends %s, outside int64", instruction, s.pc, value))
+			return
+		}
+	}
+	if err := s.link().send(srcValue); err != nil {
+		s.halt(err)
+		return
+	}
+	s.sentCount++
+	s.pc++
+}
+
+func duetRcv(s *DuetCpu, instruction DuetInstruction) {
+	tgtName := instruction.args[0][0]
+	value, err := s.link().receive(time.Second)
+	switch err {
+	case nil:
+		s.registers[tgtName] = value
+		delete(s.bigRegisters, tgtName)
+		s.pc++
+	case errDuetTimeout, io.EOF:
+		s.deadlocked = true
+		s.pc = math.MaxInt32 // should terminate program
+	default:
+		s.halt(err)
+	}
+}
+
+func duetArithmetic(s *DuetCpu, instruction DuetInstruction) {
+	if s.numeric != nil {
+		s.execNumeric(instruction)
+		return
+	}
+
+	tgtName := instruction.args[0][0]
+	srcValue := s.valueOf(instruction.args[1])
+
+	switch instruction.op {
+	case "set":
+		s.registers[tgtName] = srcValue
+	case "add":
+		s.registers[tgtName] = s.getRegister(tgtName) + srcValue
+	case "sub":
+		s.registers[tgtName] = s.getRegister(tgtName) - srcValue
+	case "mul":
+		s.registers[tgtName] = s.getRegister(tgtName) * srcValue
+		s.mulCount++
+	case "mod":
+		s.registers[tgtName] = s.getRegister(tgtName) % srcValue
+	}
+	s.pc++
+}
+
+func duetJump(s *DuetCpu, instruction DuetInstruction) {
+	if s.numeric != nil {
+		s.execNumeric(instruction)
+		return
+	}
+
+	if s.jumpHolds(instruction) {
+		s.pc += s.valueOf(instruction.args[1])
+	} else {
+		s.pc++
+	}
+}
+
+// whether a jgz or jnz jumps, given the registers as they are.
+func (s *DuetCpu) jumpHolds(instruction DuetInstruction) bool {
+	var sign int
+	if s.numeric != nil {
+		sign = s.bigValueOf(instruction.args[0]).Sign()
+	} else if value := s.valueOf(instruction.args[0]); value > 0 {
+		sign = 1
+	} else if value < 0 {
+		sign = -1
+	}
+	return (instruction.op == "jgz" && sign > 0) || (instruction.op == "jnz" && sign != 0)
+}
+
+func (s *DuetCpu) step(instruction DuetInstruction) {
+	if s.profiler != nil {
+		s.profiler.profile(s, instruction)
+	} else {
+		s.exec(instruction)
+	}
+}
+
+func (s *DuetCpu) execProgram(program []DuetInstruction) {
+	s.program = program
+	for s.pc >= 0 && s.pc < len(program) {
+		s.step(program[s.pc])
+	}
+}
+
+func (s *DuetCpu) ExecInstructions(rawInstructions string) {
+	s.execProgram(parseDuetProgram(rawInstructions))
+}
@@ -0,0 +1,108 @@
+package adventofcode2017
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type DuetAsmError struct {
+	line    int // 1-based
+	message string
+}
+
+func (e DuetAsmError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.message)
+}
+
+var duetRegisterRe = regexp.MustCompile(`^[a-z]$`)
+var duetLiteralRe = regexp.MustCompile(`^-?\d+$`)
+
+// validates every line against the Duet ISA, returning the program along
+// with every problem found. literals are normalized, so the result is
+// suitable for formatDuet.
+func assembleDuet(rawInstructions string) ([]DuetInstruction, []DuetAsmError) {
+	return assembleDuetWith(NewDuetInstructionSet(), rawInstructions)
+}
+
+func assembleDuetWith(is DuetInstructionSet, rawInstructions string) ([]DuetInstruction, []DuetAsmError) {
+	lines := strings.Split(rawInstructions, "\n")
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+
+	program := make([]DuetInstruction, len(lines))
+	errors := []DuetAsmError{}
+	report := func(jline int, format string, args ...interface{}) {
+		errors = append(errors, DuetAsmError{line: jline + 1, message: fmt.Sprintf(format, args...)})
+	}
+
+	for jline, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			report(jline, "missing instruction")
+			continue
+		}
+
+		instruction := DuetInstruction{op: fields[0], args: fields[1:]}
+		program[jline] = instruction
+
+		opcode, ok := is[instruction.op]
+		if !ok {
+			report(jline, "unknown opcode `%s`", instruction.op)
+			continue
+		}
+		if len(instruction.args) != opcode.Arity {
+			report(jline, "`%s` takes %d operand(s), got %d", instruction.op, opcode.Arity, len(instruction.args))
+			continue
+		}
+
+		for jarg, arg := range instruction.args {
+			switch {
+			case duetLiteralRe.MatchString(arg):
+				if jarg == 0 && opcode.Writes {
+					report(jline, "`%s` needs a register to write to, got `%s`", instruction.op, arg)
+				}
+				value, err := strconv.Atoi(arg)
+				if err != nil {
+					report(jline, "literal out of range `%s`", arg)
+					continue
+				}
+				instruction.args[jarg] = strconv.Itoa(value)
+			case !duetRegisterRe.MatchString(arg):
+				report(jline, "invalid register name `%s`", arg)
+			}
+		}
+	}
+
+	for pc, instruction := range program {
+		if !instruction.isJump() || len(instruction.args) != 2 {
+			continue
+		}
+		offset, ok := instruction.jumpOffset()
+		if !ok {
+			continue
+		}
+		if always, literal := instruction.jumpAlways(); literal && !always {
+			continue
+		}
+		if target := pc + offset; target < 0 || target > len(program) {
+			report(pc, "jump target %d is out of range 0..%d", target, len(program))
+		}
+	}
+
+	sort.SliceStable(errors, func(j, k int) bool { return errors[j].line < errors[k].line })
+	return program, errors
+}
+
+// renders one instruction per line, single-spaced.
+func formatDuet(program []DuetInstruction) string {
+	var out strings.Builder
+	for _, instruction := range program {
+		out.WriteString(instruction.String())
+		out.WriteString("\n")
+	}
+	return out.String()
+}
@@ -0,0 +1,102 @@
+package adventofcode2017
+
+import (
+	"io/ioutil"
+
+	"github.com/MakeNowJust/heredoc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Day18", func() {
+	Describe("assembleDuet", func() {
+		It("assembles a valid program", func() {
+			program, errors := assembleDuet("set a 1\njgz a -1\nsnd a\n")
+			Expect(errors).To(BeEmpty())
+			Expect(program).To(Equal([]DuetInstruction{
+				DuetInstruction{op: "set", args: []string{"a", "1"}},
+				DuetInstruction{op: "jgz", args: []string{"a", "-1"}},
+				DuetInstruction{op: "snd", args: []string{"a"}},
+			}))
+		})
+
+		It("reports bad opcodes", func() {
+			_, errors := assembleDuet("set a 1\nfoo a 2\n")
+			Expect(errors).To(Equal([]DuetAsmError{DuetAsmError{line: 2, message: "unknown opcode `foo`"}}))
+		})
+
+		It("reports wrong arity", func() {
+			_, errors := assembleDuet("set a\nsnd a b\n")
+			Expect(errors).To(Equal([]DuetAsmError{
+				DuetAsmError{line: 1, message: "`set` takes 2 operand(s), got 1"},
+				DuetAsmError{line: 2, message: "`snd` takes 1 operand(s), got 2"},
+			}))
+		})
+
+		It("reports literals that don't fit in an int", func() {
+			_, errors := assembleDuet("set a 1\nadd a 99999999999999999999\n")
+			Expect(errors).To(Equal([]DuetAsmError{DuetAsmError{line: 2, message: "literal out of range `99999999999999999999`"}}))
+		})
+
+		It("reports invalid register names", func() {
+			_, errors := assembleDuet("set ab 1\nadd a B\nrcv 3\nsnd 4\n")
+			Expect(errors).To(Equal([]DuetAsmError{
+				DuetAsmError{line: 1, message: "invalid register name `ab`"},
+				DuetAsmError{line: 2, message: "invalid register name `B`"},
+				DuetAsmError{line: 3, message: "`rcv` needs a register to write to, got `3`"},
+			}))
+		})
+
+		It("reports jump targets that are statically out of range", func() {
+			_, errors := assembleDuet(heredoc.Doc(`
+				set a 1
+				jnz a -2
+				jgz 1 2
+				jnz 0 -10
+				jgz a b
+			`))
+			Expect(errors).To(Equal([]DuetAsmError{DuetAsmError{line: 2, message: "jump target -1 is out of range 0..5"}}))
+		})
+
+		It("reports empty lines", func() {
+			_, errors := assembleDuet("set a 1\n\nsnd a\n")
+			Expect(errors).To(Equal([]DuetAsmError{DuetAsmError{line: 2, message: "missing instruction"}}))
+		})
+
+		It("validates against a custom instruction set", func() {
+			is := NewDuetInstructionSet()
+			is.Register(DuetOpcode{Name: "inc", Arity: 1, Writes: true})
+
+			_, errors := assembleDuetWith(is, "inc a\ninc 1\ndec a\n")
+			Expect(errors).To(Equal([]DuetAsmError{
+				DuetAsmError{line: 2, message: "`inc` needs a register to write to, got `1`"},
+				DuetAsmError{line: 3, message: "unknown opcode `dec`"},
+			}))
+		})
+
+		It("formats errors with their line number", func() {
+			Expect(DuetAsmError{line: 3, message: "unknown opcode `foo`"}.Error()).
+				To(Equal("line 3: unknown opcode `foo`"))
+		})
+	})
+
+	Describe("formatDuet", func() {
+		It("renders a canonical listing", func() {
+			program, errors := assembleDuet("  set   a  007\njgz a    -01 \nsnd\ta\n")
+			Expect(errors).To(BeEmpty())
+			Expect(formatDuet(program)).To(Equal("set a 7\njgz a -1\nsnd a\n"))
+		})
+
+		It("round trips the puzzle inputs", func() {
+			for _, filename := range []string{"day18.txt", "day23.txt"} {
+				rawData, _ := ioutil.ReadFile(filename)
+				program, errors := assembleDuet(string(rawData))
+				Expect(errors).To(BeEmpty())
+				Expect(formatDuet(program)).To(Equal(string(rawData)))
+
+				reassembled, _ := assembleDuet(formatDuet(program))
+				Expect(reassembled).To(Equal(program))
+			}
+		})
+	})
+})
@@ -0,0 +1,327 @@
+package adventofcode2017
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// whether the jump condition is a literal, and if so whether it holds.
+func (i DuetInstruction) jumpAlways() (always bool, literal bool) {
+	value, err := strconv.Atoi(i.args[0])
+	if err != nil {
+		return false, false
+	}
+	if i.op == "jgz" {
+		return value > 0, true
+	}
+	return value != 0, true
+}
+
+func (i DuetInstruction) jumpCondition(negated bool) string {
+	operators := map[string][2]string{"jnz": {"!=", "=="}, "jgz": {">", "<="}}
+	operator := operators[i.op][0]
+	if negated {
+		operator = operators[i.op][1]
+	}
+	return fmt.Sprintf("%s %s 0", i.args[0], operator)
+}
+
+//
+//  control flow graph
+//
+
+type DuetBasicBlock struct {
+	start      int
+	end        int   // exclusive
+	successors []int // start of each successor block; len(program) is the exit
+	dynamic    bool  // ends in a jump whose offset is a register
+}
+
+type DuetCfg struct {
+	blocks  []DuetBasicBlock
+	leaders map[int]bool
+}
+
+func buildDuetCfg(program []DuetInstruction) *DuetCfg {
+	cfg := DuetCfg{leaders: map[int]bool{0: true}}
+
+	for pc, instruction := range program {
+		if !instruction.isJump() {
+			continue
+		}
+		cfg.leaders[pc+1] = true
+		if offset, ok := instruction.jumpOffset(); ok {
+			cfg.leaders[pc+offset] = true
+		}
+	}
+
+	starts := []int{}
+	for pc := range cfg.leaders {
+		if pc >= 0 && pc < len(program) {
+			starts = append(starts, pc)
+		}
+	}
+	sort.Ints(starts)
+
+	for j, start := range starts {
+		end := len(program)
+		if j+1 < len(starts) {
+			end = starts[j+1]
+		}
+		block := DuetBasicBlock{start: start, end: end}
+
+		last := program[end-1]
+		switch {
+		case !last.isJump():
+			block.successors = []int{end}
+		default:
+			always, literal := last.jumpAlways()
+			offset, ok := last.jumpOffset()
+			if !literal || !always {
+				block.successors = append(block.successors, end)
+			}
+			if !literal || always {
+				if ok {
+					block.successors = append(block.successors, clampDuetTarget(end-1+offset, len(program)))
+				} else {
+					block.dynamic = true
+				}
+			}
+		}
+		cfg.blocks = append(cfg.blocks, block)
+	}
+
+	return &cfg
+}
+
+// any target outside the program terminates it.
+func clampDuetTarget(target, programLen int) int {
+	if target < 0 || target > programLen {
+		return programLen
+	}
+	return target
+}
+
+//
+//  structured pseudocode
+//
+
+type duetPseudoLine struct {
+	pc     int
+	indent int
+	text   string
+}
+
+type duetDecompiler struct {
+	program []DuetInstruction
+	cfg     *DuetCfg
+	lines   []duetPseudoLine
+	labels  map[int]bool
+}
+
+// renders the program as structured pseudocode. an instruction with the
+// wrong number of operands for its opcode is an error; unknown opcodes
+// are passed through as comments.
+func decompileDuet(rawInstructions string) (string, error) {
+	program := parseDuetProgram(rawInstructions)
+	is := NewDuetInstructionSet()
+	for pc, instruction := range program {
+		if opcode, ok := is[instruction.op]; ok && len(instruction.args) != opcode.Arity {
+			return "", DuetAsmError{line: pc + 1, message: fmt.Sprintf("`%s` takes %d operand(s), got %d", instruction.op, opcode.Arity, len(instruction.args))}
+		}
+	}
+
+	d := duetDecompiler{program: program, cfg: buildDuetCfg(program), labels: make(map[int]bool)}
+	d.emitRange(0, len(program), 0)
+
+	var out strings.Builder
+	labelled := make(map[int]bool)
+	for _, line := range d.lines {
+		if d.labels[line.pc] && !labelled[line.pc] {
+			fmt.Fprintf(&out, "L%d:\n", line.pc)
+			labelled[line.pc] = true
+		}
+		fmt.Fprintf(&out, "%s%s\n", strings.Repeat("    ", line.indent), line.text)
+	}
+	return out.String(), nil
+}
+
+func (d *duetDecompiler) emit(pc, indent int, format string, args ...interface{}) {
+	d.lines = append(d.lines, duetPseudoLine{pc: pc, indent: indent, text: fmt.Sprintf(format, args...)})
+}
+
+// the last backwards jump in (pc, hi) that targets pc, or -1.
+func (d *duetDecompiler) loopEnd(pc, hi int) int {
+	for j := hi - 1; j >= pc; j-- {
+		instruction := d.program[j]
+		if !instruction.isJump() {
+			continue
+		}
+		offset, ok := instruction.jumpOffset()
+		always, literal := instruction.jumpAlways()
+		if ok && j+offset == pc && (always || !literal) {
+			return j
+		}
+	}
+	return -1
+}
+
+// the target of an unconditional forward jump at pc, or -1.
+func (d *duetDecompiler) unconditionalTarget(pc int) int {
+	instruction := d.program[pc]
+	if !instruction.isJump() {
+		return -1
+	}
+	always, literal := instruction.jumpAlways()
+	offset, ok := instruction.jumpOffset()
+	if !always || !literal || !ok || offset <= 0 {
+		return -1
+	}
+	return pc + offset
+}
+
+func (d *duetDecompiler) emitRange(lo, hi, indent int) {
+	for pc := lo; pc < hi; {
+		if end := d.loopEnd(pc, hi); end >= 0 {
+			tail := d.program[end]
+			if always, _ := tail.jumpAlways(); always {
+				d.emit(pc, indent, "for {")
+				d.emitRange(pc, end, indent+1)
+				d.emit(end, indent, "}")
+			} else {
+				d.emit(pc, indent, "do {")
+				d.emitRange(pc, end, indent+1)
+				d.emit(end, indent, "} while (%s)", tail.jumpCondition(false))
+			}
+			pc = end + 1
+			continue
+		}
+
+		instruction := d.program[pc]
+		if !instruction.isJump() {
+			pc = d.emitStatements(pc, hi, indent)
+			continue
+		}
+
+		always, literal := instruction.jumpAlways()
+		offset, ok := instruction.jumpOffset()
+		target := pc + offset
+		switch {
+		case literal && !always:
+			// never jumps, but it may be a jump target, and it may be
+			// all there is in a block
+			d.emit(pc, indent, ";")
+		case !ok:
+			d.emitJump(pc, indent, instruction, fmt.Sprintf("goto %d + %s", pc, instruction.args[1]))
+		case !literal && target > pc+1 && target <= hi:
+			if else_ := d.unconditionalTarget(pc + 1); target == pc+2 && else_ > target && else_ <= hi {
+				d.emit(pc, indent, "if (%s) {", instruction.jumpCondition(false))
+				d.emitRange(target, else_, indent+1)
+				d.emit(pc, indent, "}")
+				pc = else_
+				continue
+			}
+			d.emit(pc, indent, "if (%s) {", instruction.jumpCondition(true))
+			d.emitRange(pc+1, target, indent+1)
+			d.emit(pc, indent, "}")
+			pc = target
+			continue
+		default:
+			d.emitJump(pc, indent, instruction, d.gotoText(target))
+		}
+		pc++
+	}
+}
+
+func (d *duetDecompiler) gotoText(target int) string {
+	if target < 0 || target >= len(d.program) {
+		return "exit"
+	}
+	d.labels[target] = true
+	return fmt.Sprintf("goto L%d", target)
+}
+
+func (d *duetDecompiler) emitJump(pc, indent int, instruction DuetInstruction, action string) {
+	if _, literal := instruction.jumpAlways(); literal {
+		d.emit(pc, indent, "%s", action)
+		return
+	}
+	d.emit(pc, indent, "if (%s) {", instruction.jumpCondition(false))
+	d.emit(pc, indent+1, "%s", action)
+	d.emit(pc, indent, "}")
+}
+
+var duetArithmeticOperators = map[string]string{"add": "+", "sub": "-", "mul": "*", "mod": "%"}
+
+// folds a run of arithmetic on the same register into one assignment,
+// e.g. `set g d`, `mul g e`, `sub g b` becomes `g = d * e - b`.
+func (d *duetDecompiler) emitStatements(pc, hi, indent int) int {
+	instruction := d.program[pc]
+	_, arithmetic := duetArithmeticOperators[instruction.op]
+	if instruction.op != "set" && !arithmetic {
+		d.emit(pc, indent, "%s", duetStatement(instruction))
+		return pc + 1
+	}
+
+	target := instruction.args[0]
+	expr, precedence := target, 0
+	end := pc
+	for ; end < hi; end++ {
+		next := d.program[end]
+		operator, ok := duetArithmeticOperators[next.op]
+		if end > pc && (!ok || d.cfg.leaders[end] || next.args[0] != target || next.args[1] == target) {
+			break
+		}
+		if next.op == "set" {
+			expr, precedence = next.args[1], 0
+			continue
+		}
+		operand := next.args[1]
+		if operator == "-" || operator == "+" {
+			if strings.HasPrefix(operand, "-") {
+				operand = operand[1:]
+				operator = map[string]string{"+": "-", "-": "+"}[operator]
+			}
+			expr, precedence = fmt.Sprintf("%s %s %s", expr, operator, operand), 2
+		} else {
+			if precedence == 2 {
+				expr = "(" + expr + ")"
+			}
+			expr, precedence = fmt.Sprintf("%s %s %s", expr, operator, operand), 1
+		}
+	}
+
+	if end == pc+1 {
+		d.emit(pc, indent, "%s", duetStatement(instruction))
+	} else {
+		d.emit(pc, indent, "%s = %s", target, expr)
+	}
+	return end
+}
+
+func duetStatement(i DuetInstruction) string {
+	switch i.op {
+	case "set":
+		return fmt.Sprintf("%s = %s", i.args[0], i.args[1])
+	case "add", "sub":
+		operator := map[string]string{"add": "+", "sub": "-"}[i.op]
+		operand := i.args[1]
+		if strings.HasPrefix(operand, "-") {
+			operand = operand[1:]
+			operator = map[string]string{"+": "-", "-": "+"}[operator]
+		}
+		if operand == "1" {
+			return i.args[0] + operator + operator
+		}
+		return fmt.Sprintf("%s %s= %s", i.args[0], operator, operand)
+	case "mul", "mod":
+		return fmt.Sprintf("%s %s= %s", i.args[0], duetArithmeticOperators[i.op], i.args[1])
+	case "snd":
+		return fmt.Sprintf("send(%s)", i.args[0])
+	case "rcv":
+		return fmt.Sprintf("%s = receive()", i.args[0])
+	}
+	return fmt.Sprintf("// %s", i)
+}
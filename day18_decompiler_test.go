@@ -0,0 +1,195 @@
+package adventofcode2017
+
+import (
+	"io/ioutil"
+
+	"github.com/MakeNowJust/heredoc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Day18", func() {
+	Describe("decompiler", func() {
+		Describe("parseDuetProgram", func() {
+			It("parses each line into an instruction", func() {
+				program := parseDuetProgram("set a 1\njgz a -1\nsnd a\n")
+				Expect(program).To(Equal([]DuetInstruction{
+					DuetInstruction{op: "set", args: []string{"a", "1"}},
+					DuetInstruction{op: "jgz", args: []string{"a", "-1"}},
+					DuetInstruction{op: "snd", args: []string{"a"}},
+				}))
+			})
+		})
+
+		Describe("buildDuetCfg", func() {
+			It("splits the program into basic blocks at jumps and jump targets", func() {
+				cfg := buildDuetCfg(parseDuetProgram(heredoc.Doc(`
+					set a 3
+					sub a 1
+					jnz a -1
+					jgz a b
+					jnz 1 2
+					snd a
+					rcv a
+				`)))
+				Expect(cfg.blocks).To(Equal([]DuetBasicBlock{
+					DuetBasicBlock{start: 0, end: 1, successors: []int{1}},
+					DuetBasicBlock{start: 1, end: 3, successors: []int{3, 1}},
+					DuetBasicBlock{start: 3, end: 4, successors: []int{4}, dynamic: true},
+					DuetBasicBlock{start: 4, end: 5, successors: []int{6}},
+					DuetBasicBlock{start: 5, end: 6, successors: []int{6}},
+					DuetBasicBlock{start: 6, end: 7, successors: []int{7}},
+				}))
+			})
+		})
+
+		Describe("decompileDuet", func() {
+			It("recovers do-while loops from backwards jumps", func() {
+				Expect(decompileDuet(heredoc.Doc(`
+					set a 3
+					sub a 1
+					jnz a -1
+				`))).To(Equal(heredoc.Doc(`
+					a = 3
+					do {
+					    a--
+					} while (a != 0)
+				`)))
+			})
+
+			It("recovers if blocks from forward jumps", func() {
+				Expect(decompileDuet(heredoc.Doc(`
+					jgz a 2
+					set b 1
+					jnz c 2
+					jnz 1 3
+					add b 5
+					mul b c
+					snd b
+				`))).To(Equal(heredoc.Doc(`
+					if (a <= 0) {
+					    b = 1
+					}
+					if (c != 0) {
+					    b = (b + 5) * c
+					}
+					send(b)
+				`)))
+			})
+
+			It("falls back to labels and gotos for unstructured jumps", func() {
+				Expect(decompileDuet(heredoc.Doc(`
+					rcv a
+					jgz a p
+					jnz 1 -2
+					jnz 1 5
+				`))).To(Equal(heredoc.Doc(`
+					for {
+					    a = receive()
+					    if (a > 0) {
+					        goto 1 + p
+					    }
+					}
+					exit
+				`)))
+			})
+
+			It("labels the targets of jumps into the middle of other blocks", func() {
+				// the loop back to pc 3 is entered from pc 0, past its
+				// start, so it can't be structured
+				Expect(decompileDuet(heredoc.Doc(`
+					jgz a 4
+					add b 1
+					sub c 1
+					jgz c 3
+					add c 2
+					sub b 1
+					jgz b -3
+					jgz c -4
+				`))).To(Equal(heredoc.Doc(`
+					if (a <= 0) {
+					    b++
+					    c--
+					L3:
+					    if (c > 0) {
+					        goto L6
+					    }
+					}
+					c += 2
+					b--
+					L6:
+					if (b > 0) {
+					    goto L3
+					}
+					if (c > 0) {
+					    goto L3
+					}
+				`)))
+			})
+
+			It("keeps jumps that never jump as statements, so they can be labelled", func() {
+				Expect(decompileDuet(heredoc.Doc(`
+					jgz a 2
+					jnz 0 0
+					set b 1
+					jnz 1 -2
+				`))).To(Equal(heredoc.Doc(`
+					if (a <= 0) {
+					L1:
+					    ;
+					}
+					b = 1
+					goto L1
+				`)))
+			})
+
+			It("complains about instructions with the wrong number of operands", func() {
+				_, err := decompileDuet("set a 1\njnz a\n")
+				Expect(err).To(MatchError("line 2: `jnz` takes 2 operand(s), got 1"))
+			})
+		})
+	})
+})
+
+var _ = Describe("Day23", func() {
+	Describe("puzzle", func() {
+		rawData, _ := ioutil.ReadFile("day23.txt")
+		instructions := string(rawData)
+
+		It("decompiles day 23", func() {
+			Expect(decompileDuet(instructions)).To(Equal(heredoc.Doc(`
+				b = 67
+				c = b
+				if (a != 0) {
+				    b = b * 100 + 100000
+				    c = b + 17000
+				}
+				for {
+				    f = 1
+				    d = 2
+				    do {
+				        e = 2
+				        do {
+				            g = d * e - b
+				            if (g == 0) {
+				                f = 0
+				            }
+				            e++
+				            g = e - b
+				        } while (g != 0)
+				        d++
+				        g = d - b
+				    } while (g != 0)
+				    if (f == 0) {
+				        h++
+				    }
+				    g = b - c
+				    if (g == 0) {
+				        exit
+				    }
+				    b += 17
+				}
+			`)))
+		})
+	})
+})
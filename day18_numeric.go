@@ -0,0 +1,168 @@
+package adventofcode2017
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+type DuetOverflowPolicy int
+
+const (
+	DuetWrap DuetOverflowPolicy = iota
+	DuetSaturate
+	DuetTrap // halts the cpu and records a fault
+)
+
+type DuetModRule int
+
+const (
+	DuetTruncatedMod DuetModRule = iota // sign follows the dividend, as in Go
+	DuetEuclideanMod                    // never negative
+)
+
+type DuetNumericModel struct {
+	bits     int // 32 or 64; zero means unbounded
+	overflow DuetOverflowPolicy
+	mod      DuetModRule
+}
+
+type DuetOverflow struct {
+	pc          int
+	instruction string
+	exact       string // the mathematically exact result
+}
+
+func (m DuetNumericModel) validate() error {
+	if m.bits < 0 || m.bits > 64 {
+		return fmt.Errorf("word size of %d bits is not supported, use at most 64", m.bits)
+	}
+	return nil
+}
+
+// without a numeric model, registers are Go ints with Go's arithmetic.
+func (s *DuetCpu) setNumericModel(model DuetNumericModel) error {
+	if err := model.validate(); err != nil {
+		return err
+	}
+	s.numeric = &model
+	if model.bits == 0 && s.bigRegisters == nil {
+		s.bigRegisters = make(map[byte]*big.Int)
+	}
+	return nil
+}
+
+func (s *DuetCpu) getBigRegister(name byte) *big.Int {
+	if value, ok := s.bigRegisters[name]; ok {
+		return new(big.Int).Set(value)
+	}
+	return big.NewInt(int64(s.getRegister(name)))
+}
+
+func (s *DuetCpu) bigValueOf(thing string) *big.Int {
+	if value, ok := new(big.Int).SetString(thing, 10); ok {
+		return value
+	}
+	return s.getBigRegister(thing[0])
+}
+
+// values that don't fit in an int live in bigRegisters, with their low
+// bits mirrored into registers.
+func (s *DuetCpu) setBigRegister(name byte, value *big.Int) {
+	if value.IsInt64() {
+		delete(s.bigRegisters, name)
+		s.registers[name] = int(value.Int64())
+		return
+	}
+	s.bigRegisters[name] = value
+	s.registers[name] = int(wrapBig(value, 64).Int64())
+}
+
+func (s *DuetCpu) halt(err error) {
+	s.fault = err
+	s.pc = math.MaxInt32 // should terminate program
+}
+
+func (s *DuetCpu) execNumeric(instruction DuetInstruction) {
+	tgtName := instruction.args[0][0]
+	srcValue := s.bigValueOf(instruction.args[1])
+	result := new(big.Int)
+
+	switch instruction.op {
+	case "set":
+		result.Set(srcValue)
+	case "add":
+		result.Add(s.getBigRegister(tgtName), srcValue)
+	case "sub":
+		result.Sub(s.getBigRegister(tgtName), srcValue)
+	case "mul":
+		result.Mul(s.getBigRegister(tgtName), srcValue)
+		s.mulCount++
+	case "mod":
+		if srcValue.Sign() == 0 {
+			s.halt(fmt.Errorf("mod by zero: `%s` at pc %d", instruction, s.pc))
+			return
+		}
+		if s.numeric.mod == DuetEuclideanMod {
+			result.Mod(s.getBigRegister(tgtName), srcValue)
+		} else {
+			result.Rem(s.getBigRegister(tgtName), srcValue)
+		}
+	case "jgz", "jnz":
+		if s.jumpHolds(instruction) {
+			if !srcValue.IsInt64() || srcValue.Int64() > math.MaxInt32 || srcValue.Int64() < math.MinInt32 {
+				s.pc = math.MaxInt32 // jumped out of any program
+				return
+			}
+			s.pc += int(srcValue.Int64())
+		} else {
+			s.pc++
+		}
+		return
+	}
+
+	if s.numeric.bits == 0 {
+		s.setBigRegister(tgtName, result)
+		s.pc++
+		return
+	}
+
+	lo, hi := bigBounds(s.numeric.bits)
+	if result.Cmp(lo) >= 0 && result.Cmp(hi) <= 0 {
+		s.registers[tgtName] = int(result.Int64())
+		s.pc++
+		return
+	}
+
+	s.overflows = append(s.overflows, DuetOverflow{pc: s.pc, instruction: instruction.String(), exact: result.String()})
+	switch s.numeric.overflow {
+	case DuetWrap:
+		s.registers[tgtName] = int(wrapBig(result, s.numeric.bits).Int64())
+	case DuetSaturate:
+		if result.Sign() < 0 {
+			s.registers[tgtName] = int(lo.Int64())
+		} else {
+			s.registers[tgtName] = int(hi.Int64())
+		}
+	case DuetTrap:
+		s.halt(fmt.Errorf("overflow: `%s` at pc %d gives %s, outside int%d", instruction, s.pc, result, s.numeric.bits))
+		return
+	}
+	s.pc++
+}
+
+func bigBounds(bits int) (*big.Int, *big.Int) {
+	hi := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+	lo := new(big.Int).Neg(hi)
+	return lo, hi.Sub(hi, big.NewInt(1))
+}
+
+// two's complement wrap-around into a signed integer of the given width.
+func wrapBig(value *big.Int, bits int) *big.Int {
+	modulus := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	rval := new(big.Int).Mod(value, modulus)
+	if rval.Bit(bits-1) == 1 {
+		rval.Sub(rval, modulus)
+	}
+	return rval
+}
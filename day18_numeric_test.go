@@ -0,0 +1,126 @@
+package adventofcode2017
+
+import (
+	"math"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Day18", func() {
+	Describe("DuetNumericModel", func() {
+		var s *DuetCpu
+
+		BeforeEach(func() {
+			s = NewDuetCpu(0)
+		})
+
+		Describe("32-bit", func() {
+			It("wraps around and reports the overflow", func() {
+				s.setNumericModel(DuetNumericModel{bits: 32, overflow: DuetWrap})
+				s.execInstruction("set a 2147483647")
+				s.execInstruction("add a 1")
+				Expect(s.getRegister('a')).To(Equal(math.MinInt32))
+				Expect(s.pc).To(Equal(2))
+				Expect(s.overflows).To(Equal([]DuetOverflow{DuetOverflow{pc: 1, instruction: "add a 1", exact: "2147483648"}}))
+			})
+
+			It("saturates", func() {
+				s.setNumericModel(DuetNumericModel{bits: 32, overflow: DuetSaturate})
+				s.execInstruction("set a -2147483647")
+				s.execInstruction("sub a 10")
+				Expect(s.getRegister('a')).To(Equal(math.MinInt32))
+				s.execInstruction("mul a -1")
+				Expect(s.getRegister('a')).To(Equal(math.MaxInt32))
+				Expect(s.overflows).To(HaveLen(2))
+			})
+
+			It("traps", func() {
+				s.setNumericModel(DuetNumericModel{bits: 32, overflow: DuetTrap})
+				s.execInstruction("set a 65536")
+				s.execInstruction("mul a a")
+				Expect(s.getRegister('a')).To(Equal(65536))
+				Expect(s.pc).To(Equal(math.MaxInt32))
+				Expect(s.fault).To(MatchError("overflow: `mul a a` at pc 1 gives 4294967296, outside int32"))
+			})
+
+			It("doesn't report values that fit", func() {
+				s.setNumericModel(DuetNumericModel{bits: 32, overflow: DuetTrap})
+				s.ExecInstructions("set a 46340\nmul a a\nsub a 2147395600\n")
+				Expect(s.getRegister('a')).To(Equal(0))
+				Expect(s.overflows).To(BeEmpty())
+				Expect(s.fault).NotTo(HaveOccurred())
+			})
+		})
+
+		Describe("64-bit", func() {
+			It("wraps around and reports the overflow", func() {
+				s.setNumericModel(DuetNumericModel{bits: 64, overflow: DuetWrap})
+				s.execInstruction("set a 9223372036854775807")
+				s.execInstruction("add a 2")
+				Expect(s.getRegister('a')).To(Equal(math.MinInt64 + 1))
+				Expect(s.overflows).To(HaveLen(1))
+			})
+		})
+
+		It("rejects word sizes wider than 64 bits", func() {
+			Expect(s.setNumericModel(DuetNumericModel{bits: 128})).To(MatchError("word size of 128 bits is not supported, use at most 64"))
+			Expect(s.numeric).To(BeNil())
+		})
+
+		Describe("unbounded", func() {
+			It("never overflows", func() {
+				s.setNumericModel(DuetNumericModel{})
+				s.execInstruction("set a 4611686018427387904")
+				s.execInstruction("mul a a")
+				s.execInstruction("mul a 4")
+				Expect(s.getBigRegister('a').String()).To(Equal("85070591730234615865843651857942052864"))
+				Expect(s.overflows).To(BeEmpty())
+			})
+
+			It("keeps small values in the plain registers", func() {
+				s.setNumericModel(DuetNumericModel{})
+				s.execInstruction("set a 99999999999999999999")
+				s.execInstruction("mod a 1000")
+				Expect(s.getRegister('a')).To(Equal(999))
+				Expect(s.bigRegisters).To(BeEmpty())
+			})
+
+			It("faults instead of sending a value that doesn't fit in an int", func() {
+				s.setNumericModel(DuetNumericModel{})
+				s.setOutgoing(make(chan int, 1))
+				s.ExecInstructions("set a 99999999999999999999\nsnd a\n")
+				Expect(s.fault).To(MatchError("snd: `snd a` at pc 1 sends 99999999999999999999, outside int64"))
+				Expect(s.sentCount).To(Equal(0))
+			})
+
+			It("jumps on big values", func() {
+				s.setNumericModel(DuetNumericModel{})
+				s.execInstruction("set a 99999999999999999999")
+				s.execInstruction("jgz a 5")
+				Expect(s.pc).To(Equal(6))
+			})
+		})
+
+		Describe("mod", func() {
+			It("truncates by default", func() {
+				s.setNumericModel(DuetNumericModel{bits: 64})
+				s.ExecInstructions("set a -7\nmod a 3\n")
+				Expect(s.getRegister('a')).To(Equal(-1))
+			})
+
+			It("can be Euclidean", func() {
+				s.setNumericModel(DuetNumericModel{bits: 64, mod: DuetEuclideanMod})
+				s.ExecInstructions("set a -7\nmod a 3\nset b -7\nmod b -3\n")
+				Expect(s.getRegister('a')).To(Equal(2))
+				Expect(s.getRegister('b')).To(Equal(2))
+			})
+
+			It("faults instead of dividing by zero", func() {
+				s.setNumericModel(DuetNumericModel{bits: 64})
+				s.ExecInstructions("set a 7\nmod a b\n")
+				Expect(s.fault).To(MatchError("mod by zero: `mod a b` at pc 1"))
+			})
+		})
+	})
+})
@@ -0,0 +1,33 @@
+package adventofcode2017_test
+
+import (
+	. "adventofcode2017"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Day18", func() {
+	Describe("DuetOpcode", func() {
+		div := DuetOpcode{Name: "div", Arity: 2, Writes: true, Handler: func(s *DuetCpu, i DuetInstruction) {
+			target := i.Args()[0][0]
+			s.SetRegister(target, s.Register(target)/s.ValueOf(i.Args()[1]))
+			s.Jump(1)
+		}}
+
+		It("can be registered from outside the package", func() {
+			s := NewDuetCpu(0)
+			s.RegisterOpcode(div)
+			s.ExecInstructions("set a 20\nset b 3\ndiv a b\n")
+			Expect(s.Fault()).NotTo(HaveOccurred())
+			Expect(s.Register('a')).To(Equal(6))
+		})
+
+		It("can be added to an instruction set", func() {
+			is := NewDuetInstructionSet()
+			is.Register(div)
+			Expect(is).To(HaveKey("div"))
+			Expect(is["div"].Arity).To(Equal(2))
+		})
+	})
+})
@@ -0,0 +1,228 @@
+package adventofcode2017
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// a native replacement for an idiom; it reports false, leaving the cpu
+// untouched, when its preconditions don't hold at runtime.
+type duetNative func(s *DuetCpu) bool
+
+type duetIdiom struct {
+	name    string
+	pattern []string // uppercase args bind registers, `#` args bind literals
+	native  func(bindings map[string]string) duetNative
+}
+
+type DuetOptimizedProgram struct {
+	instructions []DuetInstruction
+	natives      map[int]duetNative // pc → native code for the idiom starting there
+	idioms       map[int]string     // pc → name of the idiom starting there
+	fused        map[int][]string   // pc → opcodes the idiom starting there replaces
+}
+
+// the instructions are left in place, so jumps into the middle of an
+// idiom, or a native that declines to run, fall back to the original code.
+func optimizeDuetProgram(program []DuetInstruction) *DuetOptimizedProgram {
+	p := DuetOptimizedProgram{instructions: program, natives: make(map[int]duetNative), idioms: make(map[int]string), fused: make(map[int][]string)}
+	for pc := range program {
+		for _, idiom := range duetIdioms {
+			if bindings, ok := matchDuetIdiom(program[pc:], idiom.pattern); ok {
+				p.natives[pc] = idiom.native(bindings)
+				p.idioms[pc] = idiom.name
+				for _, line := range idiom.pattern {
+					p.fused[pc] = append(p.fused[pc], strings.Fields(line)[0])
+				}
+				break
+			}
+		}
+	}
+	return &p
+}
+
+func matchDuetIdiom(program []DuetInstruction, pattern []string) (map[string]string, bool) {
+	if len(program) < len(pattern) {
+		return nil, false
+	}
+	bindings := make(map[string]string)
+	bound := make(map[string]bool) // registers already claimed by a placeholder
+	for j, line := range pattern {
+		want := strings.Fields(line)
+		got := program[j]
+		if got.op != want[0] || len(got.args) != len(want)-1 {
+			return nil, false
+		}
+		for k, arg := range want[1:] {
+			actual := got.args[k]
+			_, err := strconv.Atoi(actual)
+			isLiteral := err == nil
+			switch {
+			case strings.HasPrefix(arg, "#"):
+				if !isLiteral {
+					return nil, false
+				}
+			case unicode.IsUpper(rune(arg[0])):
+				if isLiteral {
+					return nil, false
+				}
+			default:
+				if arg != actual {
+					return nil, false
+				}
+				continue
+			}
+			if previous, ok := bindings[arg]; ok {
+				if previous != actual {
+					return nil, false
+				}
+				continue
+			}
+			if !isLiteral && bound[actual] {
+				return nil, false
+			}
+			bindings[arg] = actual
+			if !isLiteral {
+				bound[actual] = true
+			}
+		}
+	}
+	return bindings, true
+}
+
+var duetDivisorLoop = []string{
+	"set G D",
+	"mul G E",
+	"sub G B",
+	"jnz G 2",
+	"set F 0",
+	"sub E -1",
+	"set G E",
+	"sub G B",
+	"jnz G -8",
+}
+
+var duetIdioms = []duetIdiom{
+	{
+		// for d := D0; d != B; d++ { for e := #K; e != B; e++ { if d*e == B { F = 0 } } }
+		name:    "composite test",
+		pattern: append(append([]string{"set E #K"}, duetDivisorLoop...), "sub D -1", "set G D", "sub G B", "jnz G -13"),
+		native:  duetCompositeTestNative,
+	},
+	{
+		// for e := E0; e != B; e++ { if D*e == B { F = 0 } }
+		name:    "divisor test",
+		pattern: duetDivisorLoop,
+		native:  duetDivisorTestNative,
+	},
+}
+
+func duetDivisorTestNative(bindings map[string]string) duetNative {
+	b, d, e, f, g := bindings["B"][0], bindings["D"][0], bindings["E"][0], bindings["F"][0], bindings["G"][0]
+	return func(s *DuetCpu) bool {
+		target, divisor, from := s.getRegister(b), s.getRegister(d), s.getRegister(e)
+		if from >= target {
+			return false // the loop would never terminate
+		}
+
+		found := false
+		if divisor == 0 {
+			found = target == 0
+		} else if target%divisor == 0 {
+			quotient := target / divisor
+			found = from <= quotient && quotient < target
+		}
+
+		if found {
+			s.registers[f] = 0
+		}
+		s.registers[e] = target
+		s.registers[g] = 0
+		s.mulCount += target - from
+		s.pc += len(duetDivisorLoop)
+		return true
+	}
+}
+
+func duetCompositeTestNative(bindings map[string]string) duetNative {
+	b, d, e, f, g := bindings["B"][0], bindings["D"][0], bindings["E"][0], bindings["F"][0], bindings["G"][0]
+	innerFrom, _ := strconv.Atoi(bindings["#K"])
+	return func(s *DuetCpu) bool {
+		target, outerFrom := s.getRegister(b), s.getRegister(d)
+		if outerFrom < 1 || innerFrom < 1 || outerFrom >= target || innerFrom >= target {
+			return false
+		}
+
+		found := false
+		for j := 1; j*j <= target && !found; j++ {
+			if target%j != 0 {
+				continue
+			}
+			k := target / j
+			found = (outerFrom <= j && j < target && innerFrom <= k && k < target) ||
+				(outerFrom <= k && k < target && innerFrom <= j && j < target)
+		}
+
+		if found {
+			s.registers[f] = 0
+		}
+		s.registers[d] = target
+		s.registers[e] = target
+		s.registers[g] = 0
+		s.mulCount += (target - outerFrom) * (target - innerFrom)
+		s.pc += len(duetDivisorLoop) + 5
+		return true
+	}
+}
+
+// natives compute with plain ints, so they are bypassed under a numeric
+// model, and they assume the stock opcodes, so they are bypassed when any
+// opcode they replace has been re-registered.
+func (s *DuetCpu) execOptimized(p *DuetOptimizedProgram) {
+	s.program = p.instructions
+	for s.pc >= 0 && s.pc < len(p.instructions) {
+		if native, ok := p.natives[s.pc]; ok && s.numeric == nil && s.builtinOpcodes(p.fused[s.pc]) && native(s) {
+			continue
+		}
+		s.step(p.instructions[s.pc])
+	}
+}
+
+func (s *DuetCpu) builtinOpcodes(names []string) bool {
+	for _, name := range names {
+		if !s.instructionSet[name].builtin {
+			return false
+		}
+	}
+	return true
+}
+
+// runs the program both with and without optimization from the same
+// initial registers, and reports any difference in the final state.
+func verifyDuetOptimization(rawInstructions string, registers map[byte]int) error {
+	program := parseDuetProgram(rawInstructions)
+
+	plain := NewDuetCpu(0)
+	optimized := NewDuetCpu(0)
+	for name, value := range registers {
+		plain.registers[name] = value
+		optimized.registers[name] = value
+	}
+
+	plain.execProgram(program)
+	optimized.execOptimized(optimizeDuetProgram(program))
+
+	if !reflect.DeepEqual(plain.registers, optimized.registers) {
+		return fmt.Errorf("registers differ: %v unoptimized, %v optimized", plain.registers, optimized.registers)
+	}
+	if plain.mulCount != optimized.mulCount {
+		return fmt.Errorf("mul count differs: %d unoptimized, %d optimized", plain.mulCount, optimized.mulCount)
+	}
+	if plain.pc != optimized.pc {
+		return fmt.Errorf("pc differs: %d unoptimized, %d optimized", plain.pc, optimized.pc)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package adventofcode2017
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Day23", func() {
+	rawData, _ := ioutil.ReadFile("day23.txt")
+	instructions := string(rawData)
+
+	Describe("optimizeDuetProgram", func() {
+		It("finds the nested loop idioms", func() {
+			p := optimizeDuetProgram(parseDuetProgram(instructions))
+			Expect(p.idioms).To(Equal(map[int]string{10: "composite test", 11: "divisor test"}))
+		})
+
+		It("binds each placeholder to a distinct register", func() {
+			_, ok := matchDuetIdiom(parseDuetProgram("set a b\nmul a a\n"), []string{"set G D", "mul G E"})
+			Expect(ok).To(BeFalse())
+
+			bindings, ok := matchDuetIdiom(parseDuetProgram("set a b\nmul a c\n"), []string{"set G D", "mul G E"})
+			Expect(ok).To(BeTrue())
+			Expect(bindings).To(Equal(map[string]string{"G": "a", "D": "b", "E": "c"}))
+		})
+
+		It("leaves other programs alone", func() {
+			p := optimizeDuetProgram(parseDuetProgram("set a 1\nadd a 2\nmul a a\n"))
+			Expect(p.natives).To(BeEmpty())
+		})
+	})
+
+	Describe("execOptimized", func() {
+		It("agrees with the unoptimized program on small inputs", func() {
+			for b := 3; b < 40; b++ {
+				program := strings.Replace(instructions, "set b 67", fmt.Sprintf("set b %d", b), 1)
+				Expect(verifyDuetOptimization(program, nil)).To(Succeed())
+			}
+		})
+
+		It("agrees with the unoptimized program over a range of inputs", func() {
+			program := strings.NewReplacer(
+				"mul b 100", "mul b 2",
+				"sub b -100000", "sub b -3",
+				"sub c -17000", "sub c -170",
+			).Replace(instructions)
+			Expect(verifyDuetOptimization(program, map[byte]int{'a': 1})).To(Succeed())
+		})
+
+		It("falls back to the instructions when a fused opcode has been re-registered", func() {
+			s := NewDuetCpu(0)
+			muls := 0
+			s.RegisterOpcode(DuetOpcode{Name: "mul", Arity: 2, Writes: true, Handler: func(s *DuetCpu, instruction DuetInstruction) {
+				muls++
+				duetArithmetic(s, instruction)
+			}})
+			s.execOptimized(optimizeDuetProgram(parseDuetProgram(instructions)))
+			Expect(muls).To(Equal(4225))
+		})
+
+		It("counts mul invocations the same way", func() {
+			s := NewDuetCpu(0)
+			s.execOptimized(optimizeDuetProgram(parseDuetProgram(instructions)))
+			Expect(s.mulCount).To(Equal(4225))
+		})
+	})
+
+	Describe("puzzle", func() {
+		It("solves star 2", func() {
+			s := NewDuetCpu(0)
+			s.registers['a'] = 1
+			s.execOptimized(optimizeDuetProgram(parseDuetProgram(instructions)))
+			fmt.Printf("d23 s2: register h is %d\n", s.getRegister('h'))
+		})
+	})
+})
@@ -0,0 +1,166 @@
+package adventofcode2017
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+type DuetCpuTraceEntry struct {
+	pc          int
+	instruction string
+	deltas      map[byte]int // register → change in value
+}
+
+func (e DuetCpuTraceEntry) String() string {
+	names := make([]int, 0, len(e.deltas))
+	for name := range e.deltas {
+		names = append(names, int(name))
+	}
+	sort.Ints(names)
+
+	changes := make([]string, len(names))
+	for j, name := range names {
+		changes[j] = fmt.Sprintf("%c%+d", name, e.deltas[byte(name)])
+	}
+	return fmt.Sprintf("%4d  %-12s %s", e.pc, e.instruction, strings.Join(changes, " "))
+}
+
+type DuetCpuLoop struct {
+	start      int // target of the backwards jump
+	end        int // pc of the backwards jump
+	iterations int
+}
+
+type DuetCpuProfiler struct {
+	instructions map[int]DuetInstruction // pc → instruction
+	executed     map[int]int             // pc → execution count
+	jumpsTaken   map[int]int             // pc → how often a jnz/jgz jumped
+	trace        []DuetCpuTraceEntry
+	traceLimit   int
+	traceNext    int
+}
+
+// a traceLimit of zero disables the trace log; otherwise only the most
+// recent traceLimit instructions are kept.
+func NewDuetCpuProfiler(traceLimit int) *DuetCpuProfiler {
+	return &DuetCpuProfiler{
+		instructions: make(map[int]DuetInstruction),
+		executed:     make(map[int]int),
+		jumpsTaken:   make(map[int]int),
+		traceLimit:   traceLimit,
+	}
+}
+
+func (s *DuetCpu) attachProfiler(p *DuetCpuProfiler) {
+	s.profiler = p
+}
+
+func copyDuetRegisters(registers map[byte]int) map[byte]int {
+	rval := make(map[byte]int, len(registers))
+	for name, value := range registers {
+		rval[name] = value
+	}
+	return rval
+}
+
+func (p *DuetCpuProfiler) profile(s *DuetCpu, instruction DuetInstruction) {
+	pc := s.pc
+	var before map[byte]int
+	if p.traceLimit > 0 {
+		before = copyDuetRegisters(s.registers)
+	}
+
+	// decided before the jump, since a taken jump can land on pc+1
+	taken := instruction.isJump() && len(instruction.args) == 2 && s.jumpHolds(instruction)
+
+	s.exec(instruction)
+
+	p.instructions[pc] = instruction
+	p.executed[pc]++
+	if taken {
+		p.jumpsTaken[pc]++
+	}
+
+	if p.traceLimit > 0 {
+		p.record(DuetCpuTraceEntry{pc: pc, instruction: instruction.String(), deltas: duetRegisterDeltas(before, s.registers)})
+	}
+}
+
+func duetRegisterDeltas(before, after map[byte]int) map[byte]int {
+	deltas := make(map[byte]int)
+	for name, value := range after {
+		if delta := value - before[name]; delta != 0 {
+			deltas[name] = delta
+		}
+	}
+	return deltas
+}
+
+func (p *DuetCpuProfiler) record(entry DuetCpuTraceEntry) {
+	if len(p.trace) < p.traceLimit {
+		p.trace = append(p.trace, entry)
+		return
+	}
+	p.trace[p.traceNext] = entry
+	p.traceNext = (p.traceNext + 1) % p.traceLimit
+}
+
+// returns the trace log, oldest entry first.
+func (p *DuetCpuProfiler) traceLog() []DuetCpuTraceEntry {
+	rval := make([]DuetCpuTraceEntry, 0, len(p.trace))
+	rval = append(rval, p.trace[p.traceNext:]...)
+	return append(rval, p.trace[:p.traceNext]...)
+}
+
+func (p *DuetCpuProfiler) writeTrace(w io.Writer) error {
+	for _, entry := range p.traceLog() {
+		if _, err := fmt.Fprintln(w, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *DuetCpuProfiler) writeTraceFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = p.writeTrace(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (p *DuetCpuProfiler) opcodeHistogram() map[string]int {
+	histogram := make(map[string]int)
+	for pc, count := range p.executed {
+		histogram[p.instructions[pc].op] += count
+	}
+	return histogram
+}
+
+// a loop is any backwards jump that was taken at least once, ordered
+// by how many times it was taken.
+func (p *DuetCpuProfiler) hotLoops() []DuetCpuLoop {
+	loops := []DuetCpuLoop{}
+	for pc, taken := range p.jumpsTaken {
+		// only literal offsets are known after the fact
+		offset, ok := p.instructions[pc].jumpOffset()
+		if !ok || offset > 0 {
+			continue
+		}
+		loops = append(loops, DuetCpuLoop{start: pc + offset, end: pc, iterations: taken})
+	}
+	sort.Slice(loops, func(j, k int) bool {
+		if loops[j].iterations == loops[k].iterations {
+			return loops[j].start < loops[k].start
+		}
+		return loops[j].iterations > loops[k].iterations
+	})
+	return loops
+}
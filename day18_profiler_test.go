@@ -0,0 +1,131 @@
+package adventofcode2017
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Day18", func() {
+	Describe("DuetCpuProfiler", func() {
+		var instructions = heredoc.Doc(`
+			set a 3
+			set b 0
+			add b 2
+			sub a 1
+			jnz a -2
+			jgz b 2
+			set c 1
+			mul b b
+		`)
+
+		It("counts how often each instruction was executed", func() {
+			s := NewDuetCpu(0)
+			p := NewDuetCpuProfiler(0)
+			s.attachProfiler(p)
+			s.ExecInstructions(instructions)
+
+			Expect(p.executed).To(Equal(map[int]int{0: 1, 1: 1, 2: 3, 3: 3, 4: 3, 5: 1, 7: 1}))
+			Expect(p.opcodeHistogram()).To(Equal(map[string]int{"set": 2, "add": 3, "sub": 3, "jnz": 3, "jgz": 1, "mul": 1}))
+			Expect(s.getRegister('b')).To(Equal(36))
+		})
+
+		It("counts how often each jump was taken", func() {
+			s := NewDuetCpu(0)
+			p := NewDuetCpuProfiler(0)
+			s.attachProfiler(p)
+			s.ExecInstructions(instructions)
+
+			Expect(p.jumpsTaken).To(Equal(map[int]int{4: 2, 5: 1}))
+		})
+
+		It("counts a taken jump that lands on the next instruction", func() {
+			s := NewDuetCpu(0)
+			p := NewDuetCpuProfiler(0)
+			s.attachProfiler(p)
+			s.ExecInstructions("jnz 1 1\njgz 0 1\nset a 1\n")
+
+			Expect(p.jumpsTaken).To(Equal(map[int]int{0: 1}))
+		})
+
+		It("detects loops from backwards jumps", func() {
+			s := NewDuetCpu(0)
+			p := NewDuetCpuProfiler(0)
+			s.attachProfiler(p)
+			s.ExecInstructions(instructions)
+
+			Expect(p.hotLoops()).To(Equal([]DuetCpuLoop{DuetCpuLoop{start: 2, end: 4, iterations: 2}}))
+		})
+
+		Describe("trace", func() {
+			It("records pc, instruction and register deltas", func() {
+				s := NewDuetCpu(0)
+				p := NewDuetCpuProfiler(100)
+				s.attachProfiler(p)
+				s.ExecInstructions(instructions)
+
+				log := p.traceLog()
+				Expect(log).To(HaveLen(13))
+				Expect(log[0]).To(Equal(DuetCpuTraceEntry{pc: 0, instruction: "set a 3", deltas: map[byte]int{'a': 3}}))
+				Expect(log[4]).To(Equal(DuetCpuTraceEntry{pc: 4, instruction: "jnz a -2", deltas: map[byte]int{}}))
+				Expect(log[12]).To(Equal(DuetCpuTraceEntry{pc: 7, instruction: "mul b b", deltas: map[byte]int{'b': 30}}))
+			})
+
+			It("is bounded, keeping the most recent entries", func() {
+				s := NewDuetCpu(0)
+				p := NewDuetCpuProfiler(3)
+				s.attachProfiler(p)
+				s.ExecInstructions(instructions)
+
+				log := p.traceLog()
+				Expect(log).To(HaveLen(3))
+				Expect(log[0].pc).To(Equal(4))
+				Expect(log[1].pc).To(Equal(5))
+				Expect(log[2].pc).To(Equal(7))
+			})
+
+			It("can be written to a file", func() {
+				s := NewDuetCpu(0)
+				p := NewDuetCpuProfiler(2)
+				s.attachProfiler(p)
+				s.ExecInstructions(instructions)
+
+				file, _ := ioutil.TempFile("", "duet-trace")
+				file.Close()
+				defer os.Remove(file.Name())
+
+				Expect(p.writeTraceFile(file.Name())).To(Succeed())
+				written, _ := ioutil.ReadFile(file.Name())
+				Expect(string(written)).To(Equal("   5  jgz b 2      \n   7  mul b b      b+30\n"))
+			})
+		})
+	})
+})
+
+var _ = Describe("Day23", func() {
+	Describe("puzzle", func() {
+		rawData, _ := ioutil.ReadFile("day23.txt")
+		instructions := string(rawData)
+
+		It("finds the loop structure of day 23", func() {
+			s := NewDuetCpu(0)
+			p := NewDuetCpuProfiler(0)
+			s.attachProfiler(p)
+			s.ExecInstructions(instructions)
+
+			loops := p.hotLoops()
+			Expect(loops).To(HaveLen(2))
+			Expect(loops[0].start).To(Equal(11))
+			Expect(loops[0].end).To(Equal(19))
+			Expect(loops[1].start).To(Equal(10))
+			Expect(loops[1].end).To(Equal(23))
+			for _, loop := range loops {
+				fmt.Printf("d23 profile: loop %d..%d iterated %d times\n", loop.start, loop.end, loop.iterations)
+			}
+		})
+	})
+})
@@ -0,0 +1,145 @@
+package adventofcode2017
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// an unbounded queue between cpus on the same thread. receive never
+// waits, since nothing could arrive while it did.
+type duetQueueTransport struct {
+	incoming []int
+	peer     *duetQueueTransport
+}
+
+func (t *duetQueueTransport) send(value int) error {
+	t.peer.incoming = append(t.peer.incoming, value)
+	return nil
+}
+
+func (t *duetQueueTransport) receive(timeout time.Duration) (int, error) {
+	if len(t.incoming) == 0 {
+		return 0, errDuetTimeout
+	}
+	value := t.incoming[0]
+	t.incoming = t.incoming[1:]
+	return value, nil
+}
+
+func (t *duetQueueTransport) close() error {
+	return nil
+}
+
+func (t *duetQueueTransport) pending() ([]int, error) {
+	return append([]int{}, t.incoming...), nil
+}
+
+func (t *duetQueueTransport) setPending(values []int) error {
+	t.incoming = append([]int(nil), values...)
+	return nil
+}
+
+type DuetScheduleSlice struct {
+	cpu   int // index of the cpu that ran
+	steps int // instructions it executed before yielding
+}
+
+type DuetScheduler struct {
+	cpus       []*DuetCpu
+	queues     []*duetQueueTransport
+	quantum    int
+	random     *rand.Rand // nil means round-robin
+	next       int        // where the round-robin search starts
+	trace      []DuetScheduleSlice
+	deadlocked bool // stopped with a cpu waiting on rcv
+}
+
+// steps cpus one at a time on the calling goroutine, each for up to
+// quantum instructions, in round-robin order.
+func NewDuetScheduler(quantum int) *DuetScheduler {
+	if quantum < 1 {
+		panic(fmt.Sprintf("quantum must be positive, got %d", quantum))
+	}
+	return &DuetScheduler{quantum: quantum}
+}
+
+// picks each slice's cpu at random from those that can run instead.
+func (sc *DuetScheduler) seed(seed int64) {
+	sc.random = rand.New(rand.NewSource(seed))
+}
+
+// adds the two cpus of a duet, each sending to the other.
+func (sc *DuetScheduler) duet() (*DuetCpu, *DuetCpu) {
+	s0, s1 := NewDuetCpu(0), NewDuetCpu(1)
+	q0, q1 := &duetQueueTransport{}, &duetQueueTransport{}
+	q0.peer, q1.peer = q1, q0
+	s0.setTransport(q0)
+	s1.setTransport(q1)
+	sc.cpus = append(sc.cpus, s0, s1)
+	sc.queues = append(sc.queues, q0, q1)
+	return s0, s1
+}
+
+func (sc *DuetScheduler) halted(j int) bool {
+	s := sc.cpus[j]
+	return s.pc < 0 || s.pc >= len(s.program)
+}
+
+func (sc *DuetScheduler) blocked(j int) bool {
+	s := sc.cpus[j]
+	return s.program[s.pc].op == "rcv" && len(sc.queues[j].incoming) == 0
+}
+
+func (sc *DuetScheduler) runnable() []int {
+	rval := []int{}
+	for j := range sc.cpus {
+		if !sc.halted(j) && !sc.blocked(j) {
+			rval = append(rval, j)
+		}
+	}
+	return rval
+}
+
+func (sc *DuetScheduler) pick(runnable []int) int {
+	if sc.random != nil {
+		return runnable[sc.random.Intn(len(runnable))]
+	}
+	for _, j := range runnable {
+		if j >= sc.next {
+			return j
+		}
+	}
+	return runnable[0]
+}
+
+// runs until every cpu has either halted or is waiting on a value that
+// will never come.
+func (sc *DuetScheduler) run(program []DuetInstruction) {
+	for _, s := range sc.cpus {
+		s.program = program
+	}
+
+	for {
+		runnable := sc.runnable()
+		if len(runnable) == 0 {
+			break
+		}
+		j := sc.pick(runnable)
+		s := sc.cpus[j]
+
+		steps := 0
+		for steps < sc.quantum && !sc.halted(j) && !sc.blocked(j) {
+			s.step(program[s.pc])
+			steps++
+		}
+		sc.trace = append(sc.trace, DuetScheduleSlice{cpu: j, steps: steps})
+		sc.next = j + 1
+	}
+
+	for j := range sc.cpus {
+		if !sc.halted(j) {
+			sc.deadlocked = true
+		}
+	}
+}
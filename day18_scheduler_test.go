@@ -0,0 +1,89 @@
+package adventofcode2017
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Day18", func() {
+	Describe("DuetScheduler", func() {
+		exchange := parseDuetProgram("snd p\nrcv a\n")
+
+		It("steps cpus round-robin", func() {
+			sc := NewDuetScheduler(1)
+			s0, s1 := sc.duet()
+			sc.run(exchange)
+
+			Expect(sc.trace).To(Equal([]DuetScheduleSlice{{0, 1}, {1, 1}, {0, 1}, {1, 1}}))
+			Expect(s0.getRegister('a')).To(Equal(1))
+			Expect(s1.getRegister('a')).To(Equal(0))
+			Expect(sc.deadlocked).To(BeFalse())
+		})
+
+		It("yields before the quantum is used up when blocked", func() {
+			sc := NewDuetScheduler(5)
+			sc.duet()
+			sc.run(exchange)
+
+			Expect(sc.trace).To(Equal([]DuetScheduleSlice{{0, 1}, {1, 2}, {0, 1}}))
+		})
+
+		It("stops on deadlock", func() {
+			sc := NewDuetScheduler(3)
+			s0, s1 := sc.duet()
+			sc.run(parseDuetProgram("set a 1\nrcv a\nsnd a\n"))
+
+			Expect(sc.deadlocked).To(BeTrue())
+			Expect(sc.trace).To(Equal([]DuetScheduleSlice{{0, 1}, {1, 1}}))
+			Expect(s0.pc).To(Equal(1))
+			Expect(s1.pc).To(Equal(1))
+		})
+
+		It("reproduces a seeded random schedule", func() {
+			program := parseDuetProgram("set i 20\nsnd i\nadd i -1\njgz i -2\nrcv a\njgz a -1\n")
+			schedule := func(seed int64) []DuetScheduleSlice {
+				sc := NewDuetScheduler(2)
+				sc.seed(seed)
+				sc.duet()
+				sc.run(program)
+				return sc.trace
+			}
+
+			Expect(schedule(17)).To(Equal(schedule(17)))
+			Expect(schedule(17)).NotTo(Equal(schedule(18)))
+		})
+
+		It("rejects an empty quantum", func() {
+			Expect(func() { NewDuetScheduler(0) }).To(Panic())
+		})
+	})
+
+	Describe("puzzle", func() {
+		rawData, _ := ioutil.ReadFile("day18.txt")
+		program := parseDuetProgram(string(rawData))
+
+		It("solves star 2 under different schedules", func() {
+			answers := map[int]bool{}
+			for _, quantum := range []int{1, 7, 1000} {
+				sc := NewDuetScheduler(quantum)
+				_, s1 := sc.duet()
+				sc.run(program)
+				answers[s1.sentCount] = true
+			}
+			for seed := int64(1); seed <= 3; seed++ {
+				sc := NewDuetScheduler(10)
+				sc.seed(seed)
+				_, s1 := sc.duet()
+				sc.run(program)
+				answers[s1.sentCount] = true
+			}
+			Expect(answers).To(HaveLen(1))
+			for answer := range answers {
+				fmt.Printf("d18 s2 scheduled: cpu 1 sent a value %d times under every schedule\n", answer)
+			}
+		})
+	})
+})
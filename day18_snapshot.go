@@ -0,0 +1,210 @@
+package adventofcode2017
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+)
+
+type DuetCpuSnapshot struct {
+	Id        int            `json:"id"`
+	Pc        int            `json:"pc"`
+	Registers map[string]int `json:"registers"` // register name → register value
+	SentCount int            `json:"sentCount"`
+	MulCount  int            `json:"mulCount"`
+	Incoming  []int          `json:"incoming"` // buffered values, oldest first
+
+	BigRegisters map[string]string `json:"bigRegisters,omitempty"` // unbounded numeric model only
+
+	Numeric    *DuetNumericModelSnapshot `json:"numeric,omitempty"` // nil means Go ints
+	Overflows  []DuetOverflowSnapshot    `json:"overflows,omitempty"`
+	Fault      string                    `json:"fault,omitempty"` // the message of the error that halted the cpu
+	Deadlocked bool                      `json:"deadlocked,omitempty"`
+}
+
+type DuetNumericModelSnapshot struct {
+	Bits     int `json:"bits"`
+	Overflow int `json:"overflow"`
+	Mod      int `json:"mod"`
+}
+
+type DuetOverflowSnapshot struct {
+	Pc          int    `json:"pc"`
+	Instruction string `json:"instruction"`
+	Exact       string `json:"exact"`
+}
+
+// the cpu must not be running while a snapshot is taken, since the
+// values it has yet to receive are read out of its transport.
+func (s *DuetCpu) snapshot() (DuetCpuSnapshot, error) {
+	incoming, err := s.link().pending()
+	if err != nil {
+		return DuetCpuSnapshot{}, err
+	}
+
+	snap := DuetCpuSnapshot{
+		Id:        s.id,
+		Pc:        s.pc,
+		Registers: make(map[string]int, len(s.registers)),
+		SentCount: s.sentCount,
+		MulCount:  s.mulCount,
+		Incoming:  append([]int{}, incoming...),
+	}
+	for name, value := range s.registers {
+		snap.Registers[string(name)] = value
+	}
+	for name, value := range s.bigRegisters {
+		if snap.BigRegisters == nil {
+			snap.BigRegisters = make(map[string]string)
+		}
+		snap.BigRegisters[string(name)] = value.String()
+	}
+
+	if s.numeric != nil {
+		snap.Numeric = &DuetNumericModelSnapshot{Bits: s.numeric.bits, Overflow: int(s.numeric.overflow), Mod: int(s.numeric.mod)}
+	}
+	for _, overflow := range s.overflows {
+		snap.Overflows = append(snap.Overflows, DuetOverflowSnapshot{Pc: overflow.pc, Instruction: overflow.instruction, Exact: overflow.exact})
+	}
+	if s.fault != nil {
+		snap.Fault = s.fault.Error()
+	}
+	snap.Deadlocked = s.deadlocked
+	return snap, nil
+}
+
+// the outgoing channel is not part of a snapshot, and must be wired up
+// again with setOutgoing.
+func restoreDuetCpu(snap DuetCpuSnapshot) (*DuetCpu, error) {
+	s := NewDuetCpu(snap.Id)
+	if err := s.restore(snap); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// restores in place, so the queued values are put back into the cpu's
+// transport rather than a new one; anything already holding it still
+// feeds this cpu.
+func (s *DuetCpu) restore(snap DuetCpuSnapshot) error {
+	registers := make(map[byte]int, len(snap.Registers))
+	for name, value := range snap.Registers {
+		if len(name) != 1 {
+			return fmt.Errorf("bad register name %q in snapshot", name)
+		}
+		registers[name[0]] = value
+	}
+	var bigRegisters map[byte]*big.Int
+	for name, value := range snap.BigRegisters {
+		if len(name) != 1 {
+			return fmt.Errorf("bad register name %q in snapshot", name)
+		}
+		if bigRegisters == nil {
+			bigRegisters = make(map[byte]*big.Int)
+		}
+		bigValue, ok := new(big.Int).SetString(value, 10)
+		if !ok {
+			return fmt.Errorf("bad value %q for register %s in snapshot", value, name)
+		}
+		bigRegisters[name[0]] = bigValue
+	}
+	var numeric *DuetNumericModel
+	if snap.Numeric != nil {
+		numeric = &DuetNumericModel{bits: snap.Numeric.Bits, overflow: DuetOverflowPolicy(snap.Numeric.Overflow), mod: DuetModRule(snap.Numeric.Mod)}
+		if err := numeric.validate(); err != nil {
+			return err
+		}
+		if numeric.bits == 0 && bigRegisters == nil {
+			bigRegisters = make(map[byte]*big.Int)
+		}
+	}
+	var overflows []DuetOverflow
+	for _, overflow := range snap.Overflows {
+		overflows = append(overflows, DuetOverflow{pc: overflow.Pc, instruction: overflow.Instruction, exact: overflow.Exact})
+	}
+	var fault error
+	if snap.Fault != "" {
+		fault = errors.New(snap.Fault)
+	}
+	if err := s.link().setPending(snap.Incoming); err != nil {
+		return err
+	}
+
+	s.id = snap.Id
+	s.pc = snap.Pc
+	s.sentCount = snap.SentCount
+	s.mulCount = snap.MulCount
+	s.registers = registers
+	s.bigRegisters = bigRegisters
+	s.numeric = numeric
+	s.overflows = overflows
+	s.fault = fault
+	s.deadlocked = snap.Deadlocked
+	return nil
+}
+
+func (s *DuetCpu) MarshalJSON() ([]byte, error) {
+	snap, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(snap)
+}
+
+func (s *DuetCpu) UnmarshalJSON(data []byte) error {
+	var snap DuetCpuSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	if s.incoming == nil {
+		*s = *NewDuetCpu(snap.Id)
+	}
+	return s.restore(snap)
+}
+
+func (s *DuetCpu) MarshalBinary() ([]byte, error) {
+	snap, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(snap); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (s *DuetCpu) UnmarshalBinary(data []byte) error {
+	var snap DuetCpuSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return err
+	}
+	if s.incoming == nil {
+		*s = *NewDuetCpu(snap.Id)
+	}
+	return s.restore(snap)
+}
+
+func saveDuetCpu(s *DuetCpu, path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func loadDuetCpu(path string) (*DuetCpu, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := new(DuetCpu)
+	if err = json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,218 @@
+package adventofcode2017
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+
+	"github.com/MakeNowJust/heredoc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Day18", func() {
+	Describe("DuetCpuSnapshot", func() {
+		var s *DuetCpu
+
+		mustSnapshot := func(s *DuetCpu) DuetCpuSnapshot {
+			snap, err := s.snapshot()
+			Expect(err).NotTo(HaveOccurred())
+			return snap
+		}
+
+		BeforeEach(func() {
+			s = NewDuetCpu(1)
+			s.setOutgoing(make(chan int, 100))
+			s.execInstruction("set a 7")
+			s.execInstruction("mul a 3")
+			s.execInstruction("snd a")
+			s.incoming <- 11
+			s.incoming <- 12
+		})
+
+		It("captures the full cpu state", func() {
+			Expect(mustSnapshot(s)).To(Equal(DuetCpuSnapshot{
+				Id:        1,
+				Pc:        3,
+				Registers: map[string]int{"a": 21, "p": 1},
+				SentCount: 1,
+				MulCount:  1,
+				Incoming:  []int{11, 12},
+			}))
+		})
+
+		It("leaves the incoming buffer intact", func() {
+			mustSnapshot(s)
+			Expect(<-s.incoming).To(Equal(11))
+			Expect(<-s.incoming).To(Equal(12))
+		})
+
+		It("restores a cpu from a snapshot", func() {
+			restored, err := restoreDuetCpu(mustSnapshot(s))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mustSnapshot(restored)).To(Equal(mustSnapshot(s)))
+
+			restored.execInstruction("rcv b")
+			Expect(restored.getRegister('b')).To(Equal(11))
+		})
+
+		It("restores in place through the same incoming channel", func() {
+			incoming := s.incoming
+			snap := mustSnapshot(s)
+			s.execInstruction("rcv b")
+			incoming <- 13
+
+			Expect(s.restore(snap)).To(Succeed())
+			Expect(s.incoming).To(Equal(incoming))
+			Expect(mustSnapshot(s).Incoming).To(Equal([]int{11, 12}))
+		})
+
+		It("rejects a queue larger than the incoming buffer", func() {
+			snap := mustSnapshot(s)
+			snap.Incoming = make([]int, cap(s.incoming)+1)
+			Expect(s.restore(snap)).To(MatchError("snapshot has 101 queued values, but the incoming buffer holds 100"))
+			Expect(mustSnapshot(s).Incoming).To(Equal([]int{11, 12}))
+		})
+
+		It("rejects register names that aren't a single byte", func() {
+			snap := mustSnapshot(s)
+			snap.Registers["ab"] = 1
+			Expect(s.restore(snap)).To(MatchError(`bad register name "ab" in snapshot`))
+
+			snap = mustSnapshot(s)
+			snap.BigRegisters = map[string]string{"": "1"}
+			Expect(s.restore(snap)).To(MatchError(`bad register name "" in snapshot`))
+		})
+
+		It("round trips through JSON", func() {
+			data, err := json.Marshal(s)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal(`{"id":1,"pc":3,"registers":{"a":21,"p":1},"sentCount":1,"mulCount":1,"incoming":[11,12]}`))
+
+			restored := new(DuetCpu)
+			Expect(json.Unmarshal(data, restored)).To(Succeed())
+			Expect(mustSnapshot(restored)).To(Equal(mustSnapshot(s)))
+		})
+
+		It("round trips through binary", func() {
+			data, err := s.MarshalBinary()
+			Expect(err).NotTo(HaveOccurred())
+
+			restored := new(DuetCpu)
+			Expect(restored.UnmarshalBinary(data)).To(Succeed())
+			Expect(mustSnapshot(restored)).To(Equal(mustSnapshot(s)))
+		})
+
+		It("captures values beyond the range of an int", func() {
+			wide := NewDuetCpu(0)
+			wide.setNumericModel(DuetNumericModel{})
+			wide.execInstruction("set a 99999999999999999999")
+
+			data, err := json.Marshal(wide)
+			Expect(err).NotTo(HaveOccurred())
+			restored := new(DuetCpu)
+			Expect(json.Unmarshal(data, restored)).To(Succeed())
+			Expect(restored.getBigRegister('a').String()).To(Equal("99999999999999999999"))
+		})
+
+		It("rejects register values that aren't numbers", func() {
+			snap := mustSnapshot(s)
+			snap.BigRegisters = map[string]string{"a": "lots"}
+			Expect(s.restore(snap)).To(MatchError(`bad value "lots" for register a in snapshot`))
+		})
+
+		It("captures the numeric model, overflows and fault", func() {
+			narrow := NewDuetCpu(0)
+			narrow.setNumericModel(DuetNumericModel{bits: 32, overflow: DuetTrap, mod: DuetEuclideanMod})
+			narrow.ExecInstructions("set a 65536\nmul a a\n")
+
+			data, err := json.Marshal(narrow)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring(`"numeric":{"bits":32,"overflow":2,"mod":1}`))
+			Expect(string(data)).To(ContainSubstring(`"overflows":[{"pc":1,"instruction":"mul a a","exact":"4294967296"}]`))
+
+			fromJSON := new(DuetCpu)
+			Expect(json.Unmarshal(data, fromJSON)).To(Succeed())
+
+			binary, err := narrow.MarshalBinary()
+			Expect(err).NotTo(HaveOccurred())
+			fromBinary := new(DuetCpu)
+			Expect(fromBinary.UnmarshalBinary(binary)).To(Succeed())
+
+			for _, restored := range []*DuetCpu{fromJSON, fromBinary} {
+				Expect(*restored.numeric).To(Equal(*narrow.numeric))
+				Expect(restored.overflows).To(Equal(narrow.overflows))
+				Expect(restored.fault).To(MatchError("overflow: `mul a a` at pc 1 gives 4294967296, outside int32"))
+				Expect(mustSnapshot(restored)).To(Equal(mustSnapshot(narrow)))
+			}
+		})
+
+		It("rejects an unsupported numeric model", func() {
+			snap := mustSnapshot(s)
+			snap.Numeric = &DuetNumericModelSnapshot{Bits: 128}
+			Expect(s.restore(snap)).To(MatchError("word size of 128 bits is not supported, use at most 64"))
+			Expect(s.numeric).To(BeNil())
+		})
+
+		It("snapshots the values queued in a scheduled duet", func() {
+			program := parseDuetProgram("snd 1\nsnd 2\nrcv a\nrcv b\n")
+			sc := NewDuetScheduler(1)
+			s0, s1 := sc.duet()
+			s0.step(program[0])
+			s0.step(program[1])
+			snap0, snap1 := mustSnapshot(s0), mustSnapshot(s1)
+			Expect(snap1.Incoming).To(Equal([]int{1, 2}))
+
+			resumed := NewDuetScheduler(1)
+			r0, r1 := resumed.duet()
+			Expect(r0.restore(snap0)).To(Succeed())
+			Expect(r1.restore(snap1)).To(Succeed())
+
+			sc.run(program)
+			resumed.run(program)
+			Expect(mustSnapshot(r0)).To(Equal(mustSnapshot(s0)))
+			Expect(mustSnapshot(r1)).To(Equal(mustSnapshot(s1)))
+			Expect(r1.getRegister('b')).To(Equal(2))
+		})
+
+		It("refuses to snapshot a cpu talking over a socket", func() {
+			near, far := net.Pipe()
+			defer far.Close()
+			t := newDuetConnTransport(near)
+			defer t.close()
+			s.setTransport(t)
+
+			_, err := json.Marshal(s)
+			Expect(err).To(MatchError(ContainSubstring("values still on a socket can't be snapshotted")))
+		})
+
+		It("resumes a program after a checkpoint", func() {
+			program := parseDuetProgram(heredoc.Doc(`
+				set a 10
+				set b 0
+				add b a
+				sub a 1
+				jnz a -2
+			`))
+			original := NewDuetCpu(0)
+			for j := 0; j < 12; j++ {
+				original.step(program[original.pc])
+			}
+
+			file, _ := ioutil.TempFile("", "duet-checkpoint")
+			file.Close()
+			defer os.Remove(file.Name())
+			Expect(saveDuetCpu(original, file.Name())).To(Succeed())
+
+			resumed, err := loadDuetCpu(file.Name())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resumed.pc).To(Equal(original.pc))
+
+			original.execProgram(program)
+			resumed.execProgram(program)
+			Expect(mustSnapshot(resumed)).To(Equal(mustSnapshot(original)))
+			Expect(resumed.getRegister('b')).To(Equal(55))
+		})
+	})
+})
@@ -1,134 +1,15 @@
-package adventofcode2017_test
+package adventofcode2017
 
 import (
 	"fmt"
 	"io/ioutil"
 	"math"
-	"regexp"
-	"strconv"
-	"strings"
-	"time"
 
 	"github.com/MakeNowJust/heredoc"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
-type DuetCpu struct {
-	id        int
-	registers map[byte]int
-	pc        int
-	incoming  chan int
-	outgoing  chan int
-	sentCount int
-	mulCount  int
-}
-
-func NewDuetCpu(id int) *DuetCpu {
-	d := DuetCpu{registers: make(map[byte]int), incoming: make(chan int, 100)}
-	d.id = id
-	d.registers['p'] = d.id
-	return &d
-}
-
-func (s *DuetCpu) setOutgoing(outgoing chan int) {
-	s.outgoing = outgoing
-}
-
-func (s *DuetCpu) getRegister(name byte) int {
-	val, ok := s.registers[name]
-	if ok {
-		return val
-	}
-	s.registers[name] = 0
-	return 0
-}
-
-func (s *DuetCpu) valueOf(thing string) int {
-	if val, err := strconv.Atoi(thing); err == nil {
-		return val
-	} else {
-		return s.getRegister(thing[0])
-	}
-}
-
-var oneArgDuetCpuInstructionRe = regexp.MustCompile(`(snd|rcv) (-?\w+)`)
-var twoArgDuetCpuInstructionRe = regexp.MustCompile(`(set|add|sub|mul|mod|jgz|jnz) (-?\w+) (-?\w+)`)
-
-func (s *DuetCpu) execInstruction(instruction string) {
-	switch {
-	case oneArgDuetCpuInstructionRe.MatchString(instruction):
-		match := oneArgDuetCpuInstructionRe.FindStringSubmatch(instruction)
-
-		switch match[1] {
-		case "snd":
-			srcValue := s.valueOf(match[2])
-			s.outgoing <- srcValue
-			s.sentCount++
-			s.pc++
-		case "rcv":
-			tgtName := match[2][0]
-			select {
-			case s.registers[tgtName] = <-s.incoming:
-				s.pc++
-			case <-time.After(time.Second):
-				s.pc = math.MaxInt32 // should terminate program
-			}
-		default:
-			panic(fmt.Sprintf("error: could not execute instruction `%s`", match[1]))
-		}
-
-	case twoArgDuetCpuInstructionRe.MatchString(instruction):
-		match := twoArgDuetCpuInstructionRe.FindStringSubmatch(instruction)
-		tgtName := match[2][0]
-		srcValue := s.valueOf(match[3])
-
-		switch match[1] {
-		case "set":
-			s.registers[tgtName] = srcValue
-			s.pc++
-		case "add":
-			s.registers[tgtName] = s.getRegister(tgtName) + srcValue
-			s.pc++
-		case "sub":
-			s.registers[tgtName] = s.getRegister(tgtName) - srcValue
-			s.pc++
-		case "mul":
-			s.registers[tgtName] = s.getRegister(tgtName) * srcValue
-			s.pc++
-			s.mulCount++
-		case "mod":
-			s.registers[tgtName] = s.getRegister(tgtName) % srcValue
-			s.pc++
-		case "jgz":
-			if s.valueOf(match[2]) > 0 {
-				s.pc += srcValue
-			} else {
-				s.pc++
-			}
-		case "jnz":
-			if s.valueOf(match[2]) != 0 {
-				s.pc += srcValue
-			} else {
-				s.pc++
-			}
-		default:
-			panic(fmt.Sprintf("error: could not execute instruction `%s`", match[1]))
-		}
-	}
-}
-
-func (s *DuetCpu) execInstructions(rawInstructions string) {
-	instructions := strings.Split(rawInstructions, "\n")
-	instructionLen := len(instructions)
-	if len(instructions[instructionLen-1]) == 0 {
-		instructionLen -= 1
-	}
-	for s.pc < instructionLen {
-		s.execInstruction(instructions[s.pc])
-	}
-}
-
 var _ = Describe("Day18", func() {
 	Describe("DuetCpu", func() {
 		var s *DuetCpu
@@ -317,6 +198,69 @@ var _ = Describe("Day18", func() {
 			})
 		})
 
+		Describe("registerOpcode", func() {
+			It("adds opcodes to the cpu's instruction set", func() {
+				s.RegisterOpcode(DuetOpcode{Name: "inc", Arity: 1, Writes: true, Handler: func(s *DuetCpu, i DuetInstruction) {
+					s.registers[i.args[0][0]] = s.getRegister(i.args[0][0]) + 1
+					s.pc++
+				}})
+				s.RegisterOpcode(DuetOpcode{Name: "div", Arity: 2, Writes: true, Handler: func(s *DuetCpu, i DuetInstruction) {
+					s.registers[i.args[0][0]] = s.getRegister(i.args[0][0]) / s.valueOf(i.args[1])
+					s.pc++
+				}})
+
+				s.ExecInstructions("set a 20\nset b 3\ninc a\ndiv a b\n")
+				Expect(s.getRegister('a')).To(Equal(7))
+				Expect(s.pc).To(Equal(4))
+			})
+
+			It("replaces an existing opcode", func() {
+				s.RegisterOpcode(DuetOpcode{Name: "snd", Arity: 1, Handler: func(s *DuetCpu, i DuetInstruction) {
+					s.registers['s'] = s.valueOf(i.args[0])
+					s.pc++
+				}})
+
+				s.execInstruction("snd 42")
+				Expect(s.getRegister('s')).To(Equal(42))
+				Expect(s.sentCount).To(Equal(0))
+			})
+
+			It("doesn't change other cpus", func() {
+				s.RegisterOpcode(DuetOpcode{Name: "nop", Arity: 0, Handler: func(s *DuetCpu, i DuetInstruction) { s.pc++ }})
+				other := NewDuetCpu(1)
+				other.execInstruction("nop")
+				Expect(other.fault).To(MatchError(`unknown instruction "nop"`))
+			})
+
+			It("lets opcodes modify the running program", func() {
+				// toggles the instruction at pc+x between `add` and `sub`
+				s.RegisterOpcode(DuetOpcode{Name: "tgl", Arity: 1, Handler: func(s *DuetCpu, i DuetInstruction) {
+					target := s.pc + s.valueOf(i.args[0])
+					if target >= 0 && target < len(s.program) {
+						toggled := map[string]string{"add": "sub", "sub": "add"}[s.program[target].op]
+						s.program[target] = DuetInstruction{op: toggled, args: s.program[target].args}
+					}
+					s.pc++
+				}})
+
+				s.ExecInstructions("tgl 1\nadd a 5\n")
+				Expect(s.getRegister('a')).To(Equal(-5))
+			})
+
+			It("faults on an unknown opcode", func() {
+				s.execInstruction("foo a 1")
+				Expect(s.pc).To(Equal(math.MaxInt32))
+				Expect(s.fault).To(MatchError(`unknown instruction "foo a 1"`))
+			})
+
+			It("faults on the wrong number of operands", func() {
+				s.execInstruction("set a")
+				Expect(s.pc).To(Equal(math.MaxInt32))
+				Expect(s.fault).To(MatchError(`unknown instruction "set a"`))
+				Expect(s.registers).NotTo(HaveKey(byte('a')))
+			})
+		})
+
 		Describe("execInstructions", func() {
 			// It("runs a bunch of instructions, paying attention to pc", func() {
 			// 	instructions := heredoc.Doc(`
@@ -331,7 +275,7 @@ var _ = Describe("Day18", func() {
 			// 		set a 1
 			// 		jgz a -2
 			// 	`)
-			// 	s.execInstructions(instructions)
+			// 	s.ExecInstructions(instructions)
 			//  Expect(s.recovered).To(Equal(4))
 			// })
 		})
@@ -343,7 +287,7 @@ var _ = Describe("Day18", func() {
 
 		// It("solves star 1", func() {
 		// 	s := NewDuetCpu()
-		// 	s.execInstructions(string(rawData))
+		// 	s.ExecInstructions(string(rawData))
 		// 	answer := s.recovered
 		// 	fmt.Printf("d18 s1: recovered %d\n", answer)
 		// })
@@ -354,8 +298,8 @@ var _ = Describe("Day18", func() {
 			s0.setOutgoing(s1.incoming)
 			s1.setOutgoing(s0.incoming)
 
-			go s0.execInstructions(instructions)
-			s1.execInstructions(instructions)
+			go s0.ExecInstructions(instructions)
+			s1.ExecInstructions(instructions)
 			fmt.Printf("d18 s2: cpu 1 sent a value %d times\n", s1.sentCount)
 		})
 	})
@@ -372,7 +316,7 @@ var _ = Describe("Day23", func() {
 			set a 1
 		`)
 		s := NewDuetCpu(0)
-		s.execInstructions(instructions)
+		s.ExecInstructions(instructions)
 		Expect(s.mulCount).To(Equal(1))
 	})
 
@@ -382,7 +326,7 @@ var _ = Describe("Day23", func() {
 
 		It("solves star 1", func() {
 			s := NewDuetCpu(0)
-			s.execInstructions(instructions)
+			s.ExecInstructions(instructions)
 			fmt.Printf("d23 s1: mul was called %d times\n", s.mulCount)
 		})
 	})
@@ -0,0 +1,207 @@
+package adventofcode2017
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// carries the values a cpu sends and receives. receive reports
+// errDuetTimeout when nothing arrives in time, and io.EOF once the peer
+// has gone away and every value it sent has been received. pending and
+// setPending read and replace the values that have arrived but not been
+// received, oldest first, for snapshots.
+type DuetTransport interface {
+	send(value int) error
+	receive(timeout time.Duration) (int, error)
+	close() error
+	pending() ([]int, error)
+	setPending(values []int) error
+}
+
+var errDuetTimeout = errors.New("timed out waiting for a value")
+
+func (s *DuetCpu) setTransport(t DuetTransport) {
+	s.transport = t
+}
+
+func (s *DuetCpu) link() DuetTransport {
+	if s.transport != nil {
+		return s.transport
+	}
+	return duetChannelTransport{incoming: s.incoming, outgoing: s.outgoing}
+}
+
+//
+//  in memory
+//
+
+type duetChannelTransport struct {
+	incoming chan int
+	outgoing chan int
+}
+
+// returns two transports wired to each other, each buffering up to
+// size values.
+func newDuetChannelPair(size int) (DuetTransport, DuetTransport) {
+	a, b := make(chan int, size), make(chan int, size)
+	return duetChannelTransport{incoming: a, outgoing: b}, duetChannelTransport{incoming: b, outgoing: a}
+}
+
+func (t duetChannelTransport) send(value int) error {
+	t.outgoing <- value
+	return nil
+}
+
+func (t duetChannelTransport) receive(timeout time.Duration) (int, error) {
+	select {
+	case value, ok := <-t.incoming:
+		if !ok {
+			return 0, io.EOF
+		}
+		return value, nil
+	case <-time.After(timeout):
+		return 0, errDuetTimeout
+	}
+}
+
+func (t duetChannelTransport) close() error {
+	close(t.outgoing)
+	return nil
+}
+
+// drains and refills the buffer, so nothing may be sending meanwhile.
+func (t duetChannelTransport) pending() ([]int, error) {
+	values := []int{}
+	for n := len(t.incoming); n > 0; n-- {
+		value := <-t.incoming
+		values = append(values, value)
+		t.incoming <- value
+	}
+	return values, nil
+}
+
+func (t duetChannelTransport) setPending(values []int) error {
+	if len(values) > cap(t.incoming) {
+		return fmt.Errorf("snapshot has %d queued values, but the incoming buffer holds %d", len(values), cap(t.incoming))
+	}
+	for len(t.incoming) > 0 {
+		<-t.incoming
+	}
+	for _, value := range values {
+		t.incoming <- value
+	}
+	return nil
+}
+
+//
+//  over a socket
+//
+//  every frame is a one-byte kind followed by a big-endian int64.
+//
+
+const duetFrameValue byte = 'v'
+
+type duetFrame struct {
+	Kind  byte
+	Value int64
+}
+
+type duetConnTransport struct {
+	conn     net.Conn
+	incoming chan int
+	err      error // why reading stopped; only valid once incoming is closed
+	writing  sync.Mutex
+}
+
+func newDuetConnTransport(conn net.Conn) *duetConnTransport {
+	t := duetConnTransport{conn: conn, incoming: make(chan int, 100)}
+	go t.readFrames()
+	return &t
+}
+
+func (t *duetConnTransport) readFrames() {
+	defer close(t.incoming)
+	for {
+		var frame duetFrame
+		if err := binary.Read(t.conn, binary.BigEndian, &frame); err != nil {
+			if err != io.EOF {
+				t.err = err
+			}
+			return
+		}
+		if frame.Kind != duetFrameValue {
+			t.err = fmt.Errorf("unknown frame kind %q", frame.Kind)
+			return
+		}
+		t.incoming <- int(frame.Value)
+	}
+}
+
+func (t *duetConnTransport) send(value int) error {
+	t.writing.Lock()
+	defer t.writing.Unlock()
+	return binary.Write(t.conn, binary.BigEndian, duetFrame{Kind: duetFrameValue, Value: int64(value)})
+}
+
+func (t *duetConnTransport) receive(timeout time.Duration) (int, error) {
+	select {
+	case value, ok := <-t.incoming:
+		if !ok {
+			if t.err != nil {
+				return 0, t.err
+			}
+			return 0, io.EOF
+		}
+		return value, nil
+	case <-time.After(timeout):
+		return 0, errDuetTimeout
+	}
+}
+
+func (t *duetConnTransport) close() error {
+	return t.conn.Close()
+}
+
+var errDuetSocketPending = errors.New("values still on a socket can't be snapshotted")
+
+func (t *duetConnTransport) pending() ([]int, error) {
+	return nil, errDuetSocketPending
+}
+
+func (t *duetConnTransport) setPending(values []int) error {
+	return errDuetSocketPending
+}
+
+// waits for a single peer on the listener, which is closed afterwards.
+func AcceptDuet(listener net.Listener) (DuetTransport, error) {
+	defer listener.Close()
+	conn, err := listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newDuetConnTransport(conn), nil
+}
+
+func DialDuet(network, address string) (DuetTransport, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return newDuetConnTransport(conn), nil
+}
+
+// runs one half of a duet, hanging up when the program ends so the peer
+// doesn't have to wait for a timeout. the listening side is program 0
+// and the connecting side program 1.
+func RunDuet(t DuetTransport, id int, rawInstructions string) *DuetCpu {
+	s := NewDuetCpu(id)
+	s.setTransport(t)
+	s.ExecInstructions(rawInstructions)
+	t.close()
+	return s
+}
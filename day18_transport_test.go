@@ -0,0 +1,140 @@
+package adventofcode2017
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Day18", func() {
+	Describe("DuetTransport", func() {
+		exchange := heredoc.Doc(`
+			snd p
+			add p 10
+			snd p
+			rcv a
+			rcv b
+		`)
+
+		It("connects two cpus in memory", func() {
+			t0, t1 := newDuetChannelPair(100)
+			s0, s1 := NewDuetCpu(0), NewDuetCpu(1)
+			s0.setTransport(t0)
+			s1.setTransport(t1)
+
+			done := make(chan bool)
+			go func() {
+				s0.ExecInstructions(exchange)
+				done <- true
+			}()
+			s1.ExecInstructions(exchange)
+			<-done
+			Expect(s0.getRegister('a')).To(Equal(1))
+			Expect(s0.getRegister('b')).To(Equal(11))
+			Expect(s1.getRegister('a')).To(Equal(0))
+			Expect(s1.getRegister('b')).To(Equal(10))
+			Expect(s0.sentCount).To(Equal(2))
+		})
+
+		It("frames values as a kind byte and a big-endian int64", func() {
+			client, server := net.Pipe()
+			t := newDuetConnTransport(client)
+			defer t.close()
+
+			go t.send(-2)
+			frame := make([]byte, 9)
+			_, err := io.ReadFull(server, frame)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(frame).To(Equal([]byte{'v', 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}))
+		})
+
+		It("rejects unknown frames", func() {
+			client, server := net.Pipe()
+			t := newDuetConnTransport(client)
+			defer t.close()
+
+			go server.Write([]byte{'x', 0, 0, 0, 0, 0, 0, 0, 1})
+			_, err := t.receive(time.Second)
+			Expect(err).To(MatchError("unknown frame kind 'x'"))
+		})
+
+		Describe("over sockets", func() {
+			runPair := func(network, address, rawInstructions string) (*DuetCpu, *DuetCpu) {
+				listener, err := net.Listen(network, address)
+				Expect(err).NotTo(HaveOccurred())
+
+				done := make(chan *DuetCpu)
+				go func() {
+					defer GinkgoRecover()
+					t, err := AcceptDuet(listener)
+					Expect(err).NotTo(HaveOccurred())
+					done <- RunDuet(t, 0, rawInstructions)
+				}()
+
+				t, err := DialDuet(network, listener.Addr().String())
+				Expect(err).NotTo(HaveOccurred())
+				s1 := RunDuet(t, 1, rawInstructions)
+				return <-done, s1
+			}
+
+			It("exchanges values over TCP", func() {
+				s0, s1 := runPair("tcp", "127.0.0.1:0", exchange)
+				Expect(s0.getRegister('b')).To(Equal(11))
+				Expect(s1.getRegister('b')).To(Equal(10))
+				Expect(s0.Deadlocked()).To(BeFalse())
+				Expect(s1.Deadlocked()).To(BeFalse())
+				Expect(s0.fault).NotTo(HaveOccurred())
+				Expect(s1.fault).NotTo(HaveOccurred())
+			})
+
+			It("exchanges values over a unix socket", func() {
+				dir, _ := ioutil.TempDir("", "duet")
+				defer os.RemoveAll(dir)
+
+				s0, s1 := runPair("unix", filepath.Join(dir, "duet.sock"), exchange)
+				Expect(s0.getRegister('a')).To(Equal(1))
+				Expect(s1.getRegister('a')).To(Equal(0))
+			})
+
+			It("terminates both halves on deadlock", func() {
+				s0, s1 := runPair("tcp", "127.0.0.1:0", "rcv a\nsnd a\n")
+				Expect(s0.sentCount).To(Equal(0))
+				Expect(s1.sentCount).To(Equal(0))
+				Expect(s0.Deadlocked()).To(BeTrue())
+				Expect(s1.Deadlocked()).To(BeTrue())
+				Expect(s0.fault).NotTo(HaveOccurred())
+				Expect(s1.fault).NotTo(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("puzzle", func() {
+		rawData, _ := ioutil.ReadFile("day18.txt")
+		instructions := string(rawData)
+
+		It("solves star 2 over TCP", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			go func() {
+				defer GinkgoRecover()
+				t, err := AcceptDuet(listener)
+				Expect(err).NotTo(HaveOccurred())
+				RunDuet(t, 0, instructions)
+			}()
+
+			t, err := DialDuet("tcp", listener.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			s1 := RunDuet(t, 1, instructions)
+			Expect(s1.Deadlocked()).To(BeTrue())
+			fmt.Printf("d18 s2 over tcp: cpu 1 sent a value %d times\n", s1.sentCount)
+		})
+	})
+})
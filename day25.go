@@ -1,6 +1,7 @@
 package adventofcode2017
 
 import (
+	"bytes"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -12,31 +13,167 @@ type TuringMachineDirection int
 const (
 	TmRight = TuringMachineDirection(0)
 	TmLeft  = TuringMachineDirection(1)
+	TmStay  = TuringMachineDirection(2)
 )
 
+// continuing with this state halts the machine.
+const TuringMachineHalt = TuringMachineStateName("HALT")
+
+// the index of the halt state once compiled.
+const turingHalted = -1
+
 type TuringMachineStateName string
 
 type TuringMachineInstruction struct {
 	Write     int
 	Move      TuringMachineDirection
 	NextState TuringMachineStateName
+
+	// on a machine with more than one tape, the values under the heads
+	// that select this instruction, one per tape. Write and Move are for
+	// the first tape, and More for the others, in order.
+	Read []int
+	More []TuringMachineTapeAction
+}
+
+type TuringMachineTapeAction struct {
+	Write int
+	Move  TuringMachineDirection
 }
 
+// Branch[v] is what to do when the current value is v. with more than
+// one tape, each instruction says what it reads, and Branch holds them in
+// the order they were defined.
 type TuringMachineState struct {
-	Branch [2]TuringMachineInstruction
+	Branch []TuringMachineInstruction
+}
+
+// what the instruction does to each tape, in order.
+func (i TuringMachineInstruction) actions() []TuringMachineTapeAction {
+	return append([]TuringMachineTapeAction{{Write: i.Write, Move: i.Move}}, i.More...)
+}
+
+// the values under the heads that select Branch[j].
+func (s TuringMachineState) reads(j int) []int {
+	if s.Branch[j].Read != nil {
+		return s.Branch[j].Read
+	}
+	return []int{j}
+}
+
+// the index into Branch of the instruction for the values under the
+// heads, if there is one.
+func (s TuringMachineState) clauseFor(values []int) (int, bool) {
+	for j := range s.Branch {
+		if turingSameValues(s.reads(j), values) {
+			return j, true
+		}
+	}
+	return 0, false
+}
+
+func turingSameValues(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for j := range a {
+		if a[j] != b[j] {
+			return false
+		}
+	}
+	return true
 }
 
 type TuringMachine struct {
-	nextState      TuringMachineStateName
+	current        int // index of the next state
 	stepsRemaining int
-	position       int
+	positions      []int // one head per tape
 	states         map[TuringMachineStateName]TuringMachineState
-	tape           map[int]int // position → written value
+	tapes          []turingTape
+	loaded         []int // per tape, how many cells from 0 LoadTape has written
+
+	// the blueprint, as written
+	begin TuringMachineStateName
+	steps int
+	order []TuringMachineStateName // states in order of definition
+
+	// the states, indexed for the interpreter
+	stateNames []TuringMachineStateName
+	compiled   []turingCompiledState
+	base       int // with more than one tape, branches are indexed by the values read, packed in this base
+
+	warnings []string // from validating the blueprint
+}
+
+type turingCompiledInstruction struct {
+	write int
+	delta int // change in position
+	next  int // index of the next state
+	more  []turingCompiledAction
+}
+
+// what an instruction does to a tape after the first.
+type turingCompiledAction struct {
+	write int
+	delta int
+}
+
+type turingCompiledState struct {
+	name    TuringMachineStateName
+	branch  []turingCompiledInstruction        // by the value read, with one tape
+	clauses map[int]*turingCompiledInstruction // by the packed values read, with more
+}
+
+var turingDeltas = map[TuringMachineDirection]int{TmRight: 1, TmLeft: -1, TmStay: 0}
+
+var turingMoveWords = map[TuringMachineDirection]string{TmRight: "right", TmLeft: "left", TmStay: "stay"}
+
+// gives the machine k blank tapes, with the heads at 0.
+func (tm *TuringMachine) useTapes(k int) {
+	tm.tapes = make([]turingTape, k)
+	for j := range tm.tapes {
+		tm.tapes[j] = newTuringTape()
+	}
+	tm.positions = make([]int, k)
+	tm.loaded = make([]int, k)
+}
+
+// a comma-separated list of exactly k values.
+func parseTuringValues(list string, k int, line string) []int {
+	fields := strings.Split(list, ",")
+	if len(fields) != k {
+		panic(fmt.Sprintf("expected %d values, got %q", k, line))
+	}
+	values := make([]int, k)
+	for j, field := range fields {
+		value, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			panic(fmt.Sprintf("could not parse %q", line))
+		}
+		values[j] = value
+	}
+	return values
 }
 
+func joinTuringValues(values []int) string {
+	strs := make([]string, len(values))
+	for j, value := range values {
+		strs[j] = strconv.Itoa(value)
+	}
+	return strings.Join(strs, ", ")
+}
+
+// reads a Day 25 blueprint. a machine with more than one tape says so
+// after the checksum line, with `Use 2 tapes.`, and each of its clauses
+// reads, writes and moves once per tape, in tape order:
+//
+//	If the current values are 1, 0:
+//	  - Write the values 1, 1.
+//	  - Move the heads right, stay.
+//	  - Continue with state A.
 func NewTuringMachine(blueprint_raw string) *TuringMachine {
 	blueprint := strings.Split(blueprint_raw, "\n")
-	tm := TuringMachine{states: make(map[TuringMachineStateName]TuringMachineState), tape: make(map[int]int)}
+	tm := TuringMachine{states: make(map[TuringMachineStateName]TuringMachineState)}
 
 	var re *regexp.Regexp
 	var line string
@@ -49,7 +186,7 @@ func NewTuringMachine(blueprint_raw string) *TuringMachine {
 	if match == nil {
 		panic(fmt.Sprintf("could not parse %q", line))
 	}
-	tm.nextState = TuringMachineStateName(match[1])
+	tm.begin = TuringMachineStateName(match[1])
 
 	re = regexp.MustCompile(`Perform a diagnostic checksum after (\d+) steps\.`)
 	line = blueprint[1]
@@ -57,15 +194,29 @@ func NewTuringMachine(blueprint_raw string) *TuringMachine {
 	if match == nil {
 		panic(fmt.Sprintf("could not parse %q", line))
 	}
-	tm.stepsRemaining, _ = strconv.Atoi(match[1])
+	tm.steps, _ = strconv.Atoi(match[1])
+	tm.stepsRemaining = tm.steps
+
+	k, jline := 1, 2
+	re = regexp.MustCompile(`Use (\d+) tapes?\.`)
+	if jline < len(blueprint) && re.MatchString(blueprint[jline]) {
+		k, _ = strconv.Atoi(re.FindStringSubmatch(blueprint[jline])[1])
+		if k < 1 {
+			panic(fmt.Sprintf("a machine needs at least one tape, got %d", k))
+		}
+		jline++
+	}
+	tm.useTapes(k)
 
 	// repeating state sections
 	stateRe := regexp.MustCompile(`In state (\w+):`)
-	writeRe := regexp.MustCompile(`Write the value (\d+)`)
-	moveRe := regexp.MustCompile(`Move one slot to the (\w+)`)
-	continueRe := regexp.MustCompile(`Continue with state (\w+)`)
+	valueRe := regexp.MustCompile(`If the current values? (?:is|are) ([\d, ]+):`)
+	writeRe := regexp.MustCompile(`Write the values? ([\d, ]+)`)
+	moveRe := regexp.MustCompile(`Move one slot to the (\w+)|(Stay) in place|Move the heads? ([\w, ]+)\.`)
+	continueRe := regexp.MustCompile(`Continue with state (\w+)|(Halt)\.`)
+	directions := map[string]TuringMachineDirection{"right": TmRight, "left": TmLeft, "stay": TmStay, "Stay": TmStay}
 
-	jline := 3
+	jline++
 	for jline < len(blueprint) && stateRe.MatchString(blueprint[jline]) {
 		tms := TuringMachineState{}
 
@@ -76,26 +227,47 @@ func NewTuringMachine(blueprint_raw string) *TuringMachine {
 		state := TuringMachineStateName(match[1])
 
 		jline++
-		for jcurr := 0; jcurr <= 1; jcurr++ {
+		for jline < len(blueprint) && valueRe.MatchString(blueprint[jline]) {
+			match = valueRe.FindStringSubmatch(blueprint[jline])
+			read := parseTuringValues(match[1], k, blueprint[jline])
+			instruction := TuringMachineInstruction{}
+			if k == 1 {
+				if read[0] != len(tms.Branch) {
+					panic(fmt.Sprintf("expected the clause for value %d, got %q on line %d", len(tms.Branch), blueprint[jline], jline))
+				}
+			} else {
+				if _, ok := tms.clauseFor(read); ok {
+					panic(fmt.Sprintf("state %s has two clauses for the values %s", state, joinTuringValues(read)))
+				}
+				instruction.Read = read
+			}
+
 			jline++
 			match = writeRe.FindStringSubmatch(blueprint[jline])
 			if match == nil {
-				panic(fmt.Sprintf("could not parse %q on line", blueprint[jline], jline))
+				panic(fmt.Sprintf("could not parse %q on line %d", blueprint[jline], jline))
 			}
-			tms.Branch[jcurr].Write, _ = strconv.Atoi(match[1])
+			writes := parseTuringValues(match[1], k, blueprint[jline])
 
 			jline += 1
 			match = moveRe.FindStringSubmatch(blueprint[jline])
 			if match == nil {
-				panic(fmt.Sprintf("could not parse %q on line", blueprint[jline], jline))
+				panic(fmt.Sprintf("could not parse %q on line %d", blueprint[jline], jline))
 			}
-			switch match[1] {
-			case "left":
-				tms.Branch[jcurr].Move = TmLeft
-			case "right":
-				tms.Branch[jcurr].Move = TmRight
-			default:
-				panic(fmt.Sprintf("could not figure out direction %q", match[1]))
+			words := strings.Split(match[1]+match[2]+match[3], ",")
+			if len(words) != k {
+				panic(fmt.Sprintf("expected %d moves, got %q on line %d", k, blueprint[jline], jline))
+			}
+			for j, word := range words {
+				direction, ok := directions[strings.TrimSpace(word)]
+				if !ok {
+					panic(fmt.Sprintf("could not figure out direction %q", word))
+				}
+				if j == 0 {
+					instruction.Write, instruction.Move = writes[0], direction
+				} else {
+					instruction.More = append(instruction.More, TuringMachineTapeAction{Write: writes[j], Move: direction})
+				}
 			}
 
 			jline += 1
@@ -103,65 +275,373 @@ func NewTuringMachine(blueprint_raw string) *TuringMachine {
 			if match == nil {
 				panic(fmt.Sprintf("could not parse %q on line", blueprint[jline], jline))
 			}
-			tms.Branch[jcurr].NextState = TuringMachineStateName(match[1])
+			instruction.NextState = TuringMachineStateName(match[1])
+			if match[2] != "" {
+				instruction.NextState = TuringMachineHalt
+			}
 
+			tms.Branch = append(tms.Branch, instruction)
 			jline++
 		}
+		if len(tms.Branch) == 0 {
+			panic(fmt.Sprintf("state %s has no instructions", state))
+		}
 
-		tm.states[state] = tms
+		tm.defineState(state, tms)
 		jline++
 	}
 
+	warnings, err := tm.Validate()
+	if err != nil {
+		panic(err.Error())
+	}
+	tm.warnings = warnings
+
+	tm.compile()
 	return &tm
 }
 
+// a machine with no states, to be filled in with DefineState.
+func NewEmptyTuringMachine(begin string, steps int) *TuringMachine {
+	tm := TuringMachine{
+		states:         make(map[TuringMachineStateName]TuringMachineState),
+		begin:          TuringMachineStateName(begin),
+		steps:          steps,
+		stepsRemaining: steps,
+	}
+	tm.useTapes(1)
+	tm.compile()
+	return &tm
+}
+
+// adds a state, or replaces the state of the same name in place.
+func (tm *TuringMachine) DefineState(name string, state TuringMachineState) {
+	tm.defineState(TuringMachineStateName(name), state)
+	tm.compile()
+}
+
+func (tm *TuringMachine) defineState(name TuringMachineStateName, state TuringMachineState) {
+	if _, ok := tm.states[name]; !ok {
+		tm.order = append(tm.order, name)
+	}
+	tm.states[name] = state
+}
+
+// numbers the states, starting with begin. a state that is referenced
+// but never defined compiles with no instructions, so instructionFor
+// panics if the machine ever reaches it.
+//
+// with one tape, a state's branch is indexed by the value under the head.
+// with k tapes, its clauses are keyed by the k values under the heads,
+// packed into an int in a base one more than the largest symbol the
+// machine reads or writes.
+func (tm *TuringMachine) compile() {
+	current := tm.begin
+	if tm.stateNames != nil {
+		current = TuringMachineStateName(tm.NextState())
+	}
+
+	k := len(tm.tapes)
+	if k > 1 {
+		tm.base = 2
+		for _, name := range tm.order {
+			state := tm.states[name]
+			for jbranch, instruction := range state.Branch {
+				for _, value := range state.reads(jbranch) {
+					if value < 0 {
+						panic(fmt.Sprintf("state %s reads the value %d", name, value))
+					}
+					if value >= tm.base {
+						tm.base = value + 1
+					}
+				}
+				for _, action := range instruction.actions() {
+					if action.Write >= tm.base {
+						tm.base = action.Write + 1
+					}
+				}
+			}
+		}
+		// the packed values have to fit in an int
+		for j, combinations := 0, 1; j < k; j++ {
+			if combinations > int(^uint(0)>>1)/tm.base {
+				panic(fmt.Sprintf("too many combinations of %d symbols on %d tapes", tm.base, k))
+			}
+			combinations *= tm.base
+		}
+	}
+
+	indexes := make(map[TuringMachineStateName]int)
+	tm.stateNames = nil
+	tm.compiled = nil
+	index := func(name TuringMachineStateName) int {
+		if name == TuringMachineHalt {
+			return turingHalted
+		}
+		if j, ok := indexes[name]; ok {
+			return j
+		}
+		indexes[name] = len(tm.stateNames)
+		tm.stateNames = append(tm.stateNames, name)
+		return indexes[name]
+	}
+
+	index(tm.begin)
+	tm.current = index(current)
+	for jstate := 0; jstate < len(tm.stateNames); jstate++ {
+		name := tm.stateNames[jstate]
+		state := tm.states[name]
+		compiled := turingCompiledState{name: name}
+		if k == 1 {
+			compiled.branch = make([]turingCompiledInstruction, len(state.Branch))
+		} else {
+			compiled.clauses = make(map[int]*turingCompiledInstruction, len(state.Branch))
+		}
+		for jbranch, instruction := range state.Branch {
+			if len(instruction.More) != k-1 || (k > 1 && len(instruction.Read) != k) {
+				panic(fmt.Sprintf("state %s has an instruction for the wrong number of tapes, expected %d", name, k))
+			}
+			compiledInstruction := turingCompiledInstruction{
+				write: instruction.Write,
+				delta: turingDeltas[instruction.Move],
+				next:  index(instruction.NextState),
+			}
+			for _, action := range instruction.More {
+				compiledInstruction.more = append(compiledInstruction.more, turingCompiledAction{write: action.Write, delta: turingDeltas[action.Move]})
+			}
+			if k == 1 {
+				compiled.branch[jbranch] = compiledInstruction
+			} else {
+				packed := 0
+				for _, value := range instruction.Read {
+					packed = packed*tm.base + value
+				}
+				compiled.clauses[packed] = &compiledInstruction
+			}
+		}
+		tm.compiled = append(tm.compiled, compiled)
+	}
+}
+
+// renders the machine's definition in the format NewTuringMachine
+// reads. the step count is the one the blueprint started with, and
+// halting is always written as `Halt.`
+func (tm *TuringMachine) Blueprint() string {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "Begin in state %s.\n", tm.begin)
+	fmt.Fprintf(&out, "Perform a diagnostic checksum after %d steps.\n", tm.steps)
+	if len(tm.tapes) > 1 {
+		fmt.Fprintf(&out, "Use %d tapes.\n", len(tm.tapes))
+	}
+	for _, name := range tm.order {
+		fmt.Fprintf(&out, "\nIn state %s:\n", name)
+		for value, instruction := range tm.states[name].Branch {
+			if len(tm.tapes) == 1 {
+				fmt.Fprintf(&out, "  If the current value is %d:\n", value)
+				fmt.Fprintf(&out, "    - Write the value %d.\n", instruction.Write)
+				switch instruction.Move {
+				case TmRight:
+					fmt.Fprintf(&out, "    - Move one slot to the right.\n")
+				case TmLeft:
+					fmt.Fprintf(&out, "    - Move one slot to the left.\n")
+				case TmStay:
+					fmt.Fprintf(&out, "    - Stay in place.\n")
+				}
+			} else {
+				actions := instruction.actions()
+				writes, moves := make([]int, len(actions)), make([]string, len(actions))
+				for j, action := range actions {
+					writes[j], moves[j] = action.Write, turingMoveWords[action.Move]
+				}
+				fmt.Fprintf(&out, "  If the current values are %s:\n", joinTuringValues(instruction.Read))
+				fmt.Fprintf(&out, "    - Write the values %s.\n", joinTuringValues(writes))
+				fmt.Fprintf(&out, "    - Move the heads %s.\n", strings.Join(moves, ", "))
+			}
+			if instruction.NextState == TuringMachineHalt {
+				fmt.Fprintf(&out, "    - Halt.\n")
+			} else {
+				fmt.Fprintf(&out, "    - Continue with state %s.\n", instruction.NextState)
+			}
+		}
+	}
+	return out.String()
+}
+
+func (tm *TuringMachine) MarshalText() ([]byte, error) {
+	return []byte(tm.Blueprint()), nil
+}
+
 func (tm *TuringMachine) NextState() string {
-	return string(tm.nextState)
+	if tm.current == turingHalted {
+		return string(TuringMachineHalt)
+	}
+	return string(tm.stateNames[tm.current])
 }
 
+func (tm *TuringMachine) Halted() bool {
+	return tm.current == turingHalted
+}
+
+func (tm *TuringMachine) Tapes() int {
+	return len(tm.tapes)
+}
+
+// the position of the first tape's head.
 func (tm *TuringMachine) Position() int {
-	return tm.position
+	return tm.positions[0]
 }
 
+func (tm *TuringMachine) PositionOf(tape int) int {
+	return tm.positions[tape]
+}
+
+// the value on the first tape.
 func (tm *TuringMachine) TapeAt(position int) int {
-	rval, ok := tm.tape[position]
-	if !ok {
-		return 0
+	return tm.tapes[0].at(position)
+}
+
+func (tm *TuringMachine) TapeOf(tape, position int) int {
+	return tm.tapes[tape].at(position)
+}
+
+// writes values onto a tape starting at position 0, as input.
+func (tm *TuringMachine) LoadTape(tape int, values []int) {
+	for j, value := range values {
+		if value < 0 {
+			panic(fmt.Sprintf("cannot write the value %d", value))
+		}
+		tm.tapes[tape].write(j, value)
+	}
+	if len(values) > tm.loaded[tape] {
+		tm.loaded[tape] = len(values)
 	}
-	return rval
 }
 
 func (tm *TuringMachine) StepsRemaining() int {
 	return tm.stepsRemaining
 }
 
+// the number of ones on the tapes.
 func (tm *TuringMachine) Checksum() int {
-	count := 0
-	for _, value := range tm.tape {
-		if value == 1 {
-			count++
+	sum := 0
+	for j := range tm.tapes {
+		sum += tm.tapes[j].count(1)
+	}
+	return sum
+}
+
+// the number of cells holding symbol, on every tape. the tapes are blank,
+// which is zero, in both directions forever, so there is no count of
+// zeros.
+func (tm *TuringMachine) ChecksumOf(symbol int) (int, error) {
+	if symbol == 0 {
+		return 0, fmt.Errorf("the tape holds infinitely many zeros")
+	}
+	sum := 0
+	for j := range tm.tapes {
+		sum += tm.tapes[j].count(symbol)
+	}
+	return sum, nil
+}
+
+// symbol → how many cells hold it, on every tape, for every symbol but
+// the blank zero.
+func (tm *TuringMachine) Histogram() map[int]int {
+	rval := make(map[int]int)
+	for j := range tm.tapes {
+		for symbol, count := range tm.tapes[j].counts {
+			if symbol != 0 && count > 0 {
+				rval[symbol] += count
+			}
 		}
 	}
-	return count
+	return rval
 }
 
+func (s turingCompiledState) instructionFor(value int) *turingCompiledInstruction {
+	if value >= len(s.branch) {
+		panic(fmt.Sprintf("state %s has no instruction for the value %d", s.name, value))
+	}
+	return &s.branch[value]
+}
+
+// the instruction for the values under the heads.
+func (tm *TuringMachine) instructionAt() *turingCompiledInstruction {
+	state := tm.compiled[tm.current]
+	if len(tm.tapes) == 1 {
+		return state.instructionFor(tm.tapes[0].at(tm.positions[0]))
+	}
+
+	packed := 0
+	for j := range tm.tapes {
+		value := tm.tapes[j].at(tm.positions[j])
+		if value >= tm.base {
+			packed = -1
+			break
+		}
+		packed = packed*tm.base + value
+	}
+	instruction, ok := state.clauses[packed]
+	if !ok {
+		values := make([]int, len(tm.tapes))
+		for j := range tm.tapes {
+			values[j] = tm.tapes[j].at(tm.positions[j])
+		}
+		panic(fmt.Sprintf("state %s has no instruction for the values %s", state.name, joinTuringValues(values)))
+	}
+	return instruction
+}
+
+// does nothing once the machine has halted.
 func (tm *TuringMachine) Step() {
-	state := tm.states[tm.nextState]
-	instructions := state.Branch[tm.TapeAt(tm.Position())]
-	tm.tape[tm.Position()] = instructions.Write
-	tm.nextState = instructions.NextState
-	if instructions.Move == TmRight {
-		tm.position += 1
-	} else {
-		tm.position -= 1
+	if tm.current == turingHalted {
+		return
 	}
+	instruction := tm.instructionAt()
+	tm.tapes[0].write(tm.positions[0], instruction.write)
+	tm.positions[0] += instruction.delta
+	for j, action := range instruction.more {
+		tm.tapes[j+1].write(tm.positions[j+1], action.write)
+		tm.positions[j+1] += action.delta
+	}
+	tm.current = instruction.next
 	tm.stepsRemaining -= 1
 }
 
-func (tm *TuringMachine) Run() {
-	for tm.stepsRemaining > 0 {
-		tm.Step()
+// equivalent to calling Step() until no steps remain or the machine
+// halts, with the hot loop kept in local variables when there is only
+// one tape. returns the number of steps taken.
+func (tm *TuringMachine) Run() int {
+	steps := 0
+	if len(tm.tapes) > 1 {
+		for ; tm.stepsRemaining > 0 && tm.current != turingHalted; steps++ {
+			tm.Step()
+		}
+		return steps
+	}
+
+	compiled, tape := tm.compiled, &tm.tapes[0]
+	current, position := tm.current, tm.positions[0]
+	for ; tm.stepsRemaining > 0 && current != turingHalted; tm.stepsRemaining-- {
+		index := position + tape.origin
+		if index < 0 || index >= len(tape.cells) {
+			tape.grow(position)
+			index = position + tape.origin
+		}
+		cell := &tape.cells[index]
+		instruction := compiled[current].instructionFor(*cell)
+		if instruction.write >= len(tape.counts) {
+			tape.growCounts(instruction.write)
+		}
+		tape.counts[*cell]--
+		tape.counts[instruction.write]++
+		*cell = instruction.write
+		position += instruction.delta
+		current = instruction.next
+		steps++
 	}
+	tm.current, tm.positions[0] = current, position
+	return steps
 }
 
 func (tm *TuringMachine) State(name string) TuringMachineState {
@@ -0,0 +1,299 @@
+package adventofcode2017
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//
+//  a macro machine: the tape is cut into blocks of blockSize cells, and
+//  the head always sits on a block boundary, facing into the block on one
+//  side. a macro step runs the machine through that block until it comes
+//  out of either side. the tape is run-length encoded, so when a macro
+//  step leaves the state and direction unchanged, it applies to a whole
+//  run of identical blocks at once (the chain rule).
+//
+
+type macroFacing int
+
+const (
+	facingRight = macroFacing(0) // the head is on the first cell of the block to its right
+	facingLeft  = macroFacing(1) // the head is on the last cell of the block to its left
+)
+
+type macroRun struct {
+	block int // interned block id
+	count int
+}
+
+type macroKey struct {
+	state  int
+	facing macroFacing
+	block  int
+}
+
+type macroTransition struct {
+	block  int // what the block becomes
+	state  int
+	facing macroFacing // which way the head left the block
+	steps  int
+	stuck  bool // halts, loops or fails inside the block
+}
+
+type macroMachine struct {
+	tm          *TuringMachine
+	blockSize   int
+	blocks      [][]int
+	blockIds    map[string]int
+	transitions map[macroKey]macroTransition
+
+	left, right []macroRun // nearest run last; beyond them the tape is blank
+	boundary    int        // position of the first cell right of the head's boundary
+	state       int
+	facing      macroFacing
+}
+
+func (m *macroMachine) intern(cells []int) int {
+	strs := make([]string, len(cells))
+	for j, cell := range cells {
+		strs[j] = strconv.Itoa(cell)
+	}
+	key := strings.Join(strs, ",")
+	if id, ok := m.blockIds[key]; ok {
+		return id
+	}
+	m.blockIds[key] = len(m.blocks)
+	m.blocks = append(m.blocks, append([]int(nil), cells...))
+	return m.blockIds[key]
+}
+
+func pushMacroRun(stack []macroRun, block, count int) []macroRun {
+	if len(stack) > 0 && stack[len(stack)-1].block == block {
+		stack[len(stack)-1].count += count
+		return stack
+	}
+	return append(stack, macroRun{block: block, count: count})
+}
+
+// the nearest block on a side, and the side without it. block 0 is blank.
+func popMacroBlock(stack []macroRun) (int, []macroRun) {
+	if len(stack) == 0 {
+		return 0, stack
+	}
+	top := stack[len(stack)-1]
+	if top.count == 1 {
+		return top.block, stack[:len(stack)-1]
+	}
+	stack[len(stack)-1].count--
+	return top.block, stack
+}
+
+// runs the base machine inside a single block.
+func (m *macroMachine) transition(key macroKey) macroTransition {
+	if t, ok := m.transitions[key]; ok {
+		return t
+	}
+
+	cells := append([]int(nil), m.blocks[key.block]...)
+	cell, state, steps := 0, key.state, 0
+	if key.facing == facingLeft {
+		cell = m.blockSize - 1
+	}
+	seen := make(map[string]bool)
+	t := macroTransition{stuck: true}
+	for {
+		if state == turingHalted || cells[cell] >= len(m.tm.compiled[state].branch) {
+			break
+		}
+		config := fmt.Sprint(cell, state, cells)
+		if seen[config] {
+			break
+		}
+		seen[config] = true
+
+		instruction := m.tm.compiled[state].branch[cells[cell]]
+		cells[cell] = instruction.write
+		cell += instruction.delta
+		state = instruction.next
+		steps++
+
+		if cell < 0 || cell >= m.blockSize {
+			t = macroTransition{state: state, facing: facingRight, steps: steps}
+			if cell < 0 {
+				t.facing = facingLeft
+			}
+			t.block = m.intern(cells)
+			break
+		}
+	}
+	m.transitions[key] = t
+	return t
+}
+
+// takes macro steps until a macro step would overshoot the remaining
+// steps, or gets stuck, or the machine halts.
+func (m *macroMachine) run() int {
+	taken := 0
+	for m.state != turingHalted && m.tm.stepsRemaining > 0 {
+		var block int
+		if m.facing == facingRight {
+			block, m.right = popMacroBlock(m.right)
+		} else {
+			block, m.left = popMacroBlock(m.left)
+		}
+		t := m.transition(macroKey{state: m.state, facing: m.facing, block: block})
+		if t.stuck || t.steps > m.tm.stepsRemaining {
+			m.unpop(block)
+			break
+		}
+
+		// the chain rule: the rest of the run goes the same way
+		repeat := 1
+		if t.state == m.state && t.facing == m.facing {
+			ahead := &m.right
+			if m.facing == facingLeft {
+				ahead = &m.left
+			}
+			more := m.tm.stepsRemaining/t.steps - 1 // as many as there are steps for
+			if n := len(*ahead); n == 0 {
+				if block != 0 {
+					more = 0
+				}
+			} else if top := &(*ahead)[n-1]; top.block != block {
+				more = 0
+			} else {
+				if top.count < more {
+					more = top.count
+				}
+				top.count -= more
+				if top.count == 0 {
+					*ahead = (*ahead)[:n-1]
+				}
+			}
+			repeat += more
+		}
+
+		if t.facing == facingRight {
+			m.left = pushMacroRun(m.left, t.block, repeat)
+			if m.facing == facingRight {
+				m.boundary += repeat * m.blockSize
+			}
+		} else {
+			m.right = pushMacroRun(m.right, t.block, repeat)
+			if m.facing == facingLeft {
+				m.boundary -= repeat * m.blockSize
+			}
+		}
+		m.state, m.facing = t.state, t.facing
+		m.tm.stepsRemaining -= repeat * t.steps
+		taken += repeat * t.steps
+	}
+	return taken
+}
+
+func (m *macroMachine) unpop(block int) {
+	if m.facing == facingRight {
+		m.right = pushMacroRun(m.right, block, 1)
+	} else {
+		m.left = pushMacroRun(m.left, block, 1)
+	}
+}
+
+// like Run, but in macro steps of blockSize cells, finishing with
+// ordinary steps when a macro step would go past the step count or the
+// machine halts inside a block. the end result is identical to Run's.
+// blocks are cut from a single tape, so a machine with more than one
+// tape just runs.
+func (tm *TuringMachine) RunAccelerated(blockSize int) int {
+	if blockSize < 1 {
+		panic(fmt.Sprintf("block size must be positive, got %d", blockSize))
+	}
+	if tm.current == turingHalted {
+		return 0
+	}
+	if len(tm.tapes) > 1 {
+		return tm.Run()
+	}
+
+	m := macroMachine{
+		tm:          tm,
+		blockSize:   blockSize,
+		blockIds:    make(map[string]int),
+		transitions: make(map[macroKey]macroTransition),
+		boundary:    tm.positions[0],
+		state:       tm.current,
+		facing:      facingRight,
+	}
+	m.intern(make([]int, blockSize))
+	m.load()
+
+	taken := m.run()
+	m.store()
+	return taken + tm.Run()
+}
+
+// cuts the dense tape into blocks, with a boundary at the head.
+func (m *macroMachine) load() {
+	lo, hi := m.boundary, m.boundary
+	tape := &m.tm.tapes[0]
+	for index, value := range tape.cells {
+		if value != 0 {
+			position := index - tape.origin
+			if position < lo {
+				lo = position
+			}
+			if position >= hi {
+				hi = position + 1
+			}
+		}
+	}
+
+	block := func(start int) int {
+		cells := make([]int, m.blockSize)
+		for j := range cells {
+			cells[j] = tape.at(start + j)
+		}
+		return m.intern(cells)
+	}
+	for start := m.boundary + (hi-m.boundary+m.blockSize-1)/m.blockSize*m.blockSize - m.blockSize; start >= m.boundary; start -= m.blockSize {
+		m.right = pushMacroRun(m.right, block(start), 1)
+	}
+	for start := m.boundary - (m.boundary-lo+m.blockSize-1)/m.blockSize*m.blockSize; start < m.boundary; start += m.blockSize {
+		m.left = pushMacroRun(m.left, block(start), 1)
+	}
+}
+
+// writes the blocks back onto a fresh dense tape.
+func (m *macroMachine) store() {
+	tape := newTuringTape()
+	position := m.boundary
+	for j := len(m.right) - 1; j >= 0; j-- {
+		for n := 0; n < m.right[j].count; n++ {
+			for _, value := range m.blocks[m.right[j].block] {
+				if value != 0 {
+					tape.write(position, value)
+				}
+				position++
+			}
+		}
+	}
+	position = m.boundary
+	for j := len(m.left) - 1; j >= 0; j-- {
+		for n := 0; n < m.left[j].count; n++ {
+			position -= m.blockSize
+			for k, value := range m.blocks[m.left[j].block] {
+				if value != 0 {
+					tape.write(position+k, value)
+				}
+			}
+		}
+	}
+
+	m.tm.tapes[0] = tape
+	m.tm.current = m.state
+	m.tm.positions[0] = m.boundary
+	if m.facing == facingLeft {
+		m.tm.positions[0]--
+	}
+}
@@ -0,0 +1,142 @@
+package adventofcode2017
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// everything needed to resume a run. the tape is sparse: position → value
+// for every cell that isn't zero. loaded is how many cells from 0 were
+// written with LoadTape before the run. position, tape and loaded are the
+// first tape's, and a machine with more tapes has the rest in
+// other_tapes.
+type turingCheckpoint struct {
+	Blueprint      string                 `json:"blueprint"`
+	State          string                 `json:"state"`
+	Position       int                    `json:"position"`
+	StepsRemaining int                    `json:"steps_remaining"`
+	Tape           map[int]int            `json:"tape"`
+	Loaded         int                    `json:"loaded,omitempty"`
+	OtherTapes     []turingCheckpointTape `json:"other_tapes,omitempty"`
+}
+
+type turingCheckpointTape struct {
+	Position int         `json:"position"`
+	Tape     map[int]int `json:"tape"`
+	Loaded   int         `json:"loaded,omitempty"`
+}
+
+func (t *turingTape) sparse() map[int]int {
+	rval := make(map[int]int)
+	for index, value := range t.cells {
+		if value != 0 {
+			rval[index-t.origin] = value
+		}
+	}
+	return rval
+}
+
+// writes the machine, mid-run or not, as JSON.
+func (tm *TuringMachine) SaveCheckpoint(w io.Writer) error {
+	checkpoint := turingCheckpoint{
+		Blueprint:      tm.Blueprint(),
+		State:          tm.NextState(),
+		Position:       tm.positions[0],
+		StepsRemaining: tm.stepsRemaining,
+		Tape:           tm.tapes[0].sparse(),
+		Loaded:         tm.loaded[0],
+	}
+	for j := 1; j < len(tm.tapes); j++ {
+		checkpoint.OtherTapes = append(checkpoint.OtherTapes, turingCheckpointTape{Position: tm.positions[j], Tape: tm.tapes[j].sparse(), Loaded: tm.loaded[j]})
+	}
+	return json.NewEncoder(w).Encode(checkpoint)
+}
+
+// reads a machine written by SaveCheckpoint, ready to carry on running.
+func LoadTuringMachine(r io.Reader) (tm *TuringMachine, err error) {
+	var checkpoint turingCheckpoint
+	if err := json.NewDecoder(r).Decode(&checkpoint); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tm, err = nil, fmt.Errorf("bad blueprint in checkpoint: %v", p)
+		}
+	}()
+	tm = NewTuringMachine(checkpoint.Blueprint)
+
+	current, ok := turingHalted, checkpoint.State == string(TuringMachineHalt)
+	for index, name := range tm.stateNames {
+		if string(name) == checkpoint.State {
+			current, ok = index, true
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("checkpoint is in unknown state %s", checkpoint.State)
+	}
+
+	tapes := append([]turingCheckpointTape{{Position: checkpoint.Position, Tape: checkpoint.Tape, Loaded: checkpoint.Loaded}}, checkpoint.OtherTapes...)
+	if len(tapes) != len(tm.tapes) {
+		return nil, fmt.Errorf("checkpoint has %d tapes, but the blueprint uses %d", len(tapes), len(tm.tapes))
+	}
+
+	// the heads move at most one cell a step, so in a genuine checkpoint
+	// no head is further from the start than the steps taken, and no
+	// cell is either, unless it was loaded before the run. this keeps a
+	// corrupt position from growing a tape without bound.
+	taken := tm.steps - checkpoint.StepsRemaining
+	if taken < 0 {
+		return nil, fmt.Errorf("checkpoint has %d steps remaining, more than the blueprint's %d", checkpoint.StepsRemaining, tm.steps)
+	}
+	for _, tape := range tapes {
+		if tape.Loaded < 0 {
+			return nil, fmt.Errorf("checkpoint has %d loaded cells", tape.Loaded)
+		}
+		if tape.Position < -taken || tape.Position > taken {
+			return nil, fmt.Errorf("checkpoint has the head at %d, more than %d steps from the start", tape.Position, taken)
+		}
+		for position, value := range tape.Tape {
+			if value < 0 {
+				return nil, fmt.Errorf("checkpoint has the value %d at %d", value, position)
+			}
+			if position < -taken || (position > taken && position >= tape.Loaded) {
+				return nil, fmt.Errorf("checkpoint has the value %d at %d, more than %d steps from the start", value, position, taken)
+			}
+		}
+	}
+
+	tm.current = current
+	tm.stepsRemaining = checkpoint.StepsRemaining
+	for j, tape := range tapes {
+		tm.positions[j] = tape.Position
+		tm.loaded[j] = tape.Loaded
+		for position, value := range tape.Tape {
+			tm.tapes[j].write(position, value)
+		}
+	}
+	return tm, nil
+}
+
+// renders the first tape's cells from..to inclusive, like `..1[1]01..`,
+// with the head's cell in brackets when it's in the window. the dots
+// stand for the rest of the tape.
+func (tm *TuringMachine) TapeWindow(from, to int) string {
+	if from > to {
+		panic(fmt.Sprintf("empty tape window %d..%d", from, to))
+	}
+	var out bytes.Buffer
+	out.WriteString("..")
+	for position := from; position <= to; position++ {
+		if position == tm.positions[0] {
+			fmt.Fprintf(&out, "[%d]", tm.tapes[0].at(position))
+		} else {
+			out.WriteString(strconv.Itoa(tm.tapes[0].at(position)))
+		}
+	}
+	out.WriteString("..")
+	return out.String()
+}
@@ -0,0 +1,103 @@
+package adventofcode2017
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var turingMoveLetters = map[TuringMachineDirection]string{TmRight: "R", TmLeft: "L", TmStay: "S"}
+
+// the states that can be reached from the start state.
+func (tm *TuringMachine) reachable() map[TuringMachineStateName]bool {
+	seen := map[TuringMachineStateName]bool{tm.begin: true}
+	queue := []TuringMachineStateName{tm.begin}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, instruction := range tm.states[name].Branch {
+			if next := instruction.NextState; next != TuringMachineHalt && !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return seen
+}
+
+// an edge label like `0/1,R`: read 0, write 1, move right. with more
+// tapes, `1,0/1,1,RR` reads, writes and moves each tape in turn.
+func turingEdgeLabel(reads []int, instruction TuringMachineInstruction) string {
+	actions := instruction.actions()
+	values, writes, moves := make([]string, len(reads)), make([]string, len(actions)), ""
+	for j, value := range reads {
+		values[j] = strconv.Itoa(value)
+	}
+	for j, action := range actions {
+		writes[j] = strconv.Itoa(action.Write)
+		moves += turingMoveLetters[action.Move]
+	}
+	return fmt.Sprintf("%s/%s,%s", strings.Join(values, ","), strings.Join(writes, ","), moves)
+}
+
+// renders the state diagram in Graphviz DOT. unreachable states are
+// dashed, and halting goes to a double-circled HALT node.
+func (tm *TuringMachine) Dot() string {
+	reachable := tm.reachable()
+	halts := false
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "digraph turing {\n")
+	fmt.Fprintf(&out, "  rankdir=LR;\n")
+	fmt.Fprintf(&out, "  start [shape=point];\n")
+	for _, name := range tm.order {
+		if reachable[name] {
+			fmt.Fprintf(&out, "  %q [shape=circle];\n", name)
+		} else {
+			fmt.Fprintf(&out, "  %q [shape=circle, style=dashed, label=%q];\n", name, name+" (unreachable)")
+		}
+	}
+	fmt.Fprintf(&out, "  start -> %q;\n", tm.begin)
+	for _, name := range tm.order {
+		for j, instruction := range tm.states[name].Branch {
+			halts = halts || instruction.NextState == TuringMachineHalt
+			fmt.Fprintf(&out, "  %q -> %q [label=%q];\n", name, instruction.NextState, turingEdgeLabel(tm.states[name].reads(j), instruction))
+		}
+	}
+	if halts {
+		fmt.Fprintf(&out, "  %q [shape=doublecircle];\n", TuringMachineHalt)
+	}
+	fmt.Fprintf(&out, "}\n")
+	return out.String()
+}
+
+// renders the state diagram as a Mermaid state diagram. halting is the
+// final state, and unreachable states are dashed.
+func (tm *TuringMachine) Mermaid() string {
+	reachable := tm.reachable()
+	var unreachable []TuringMachineStateName
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "stateDiagram-v2\n")
+	fmt.Fprintf(&out, "  [*] --> %s\n", tm.begin)
+	for _, name := range tm.order {
+		if !reachable[name] {
+			unreachable = append(unreachable, name)
+		}
+		for j, instruction := range tm.states[name].Branch {
+			next := string(instruction.NextState)
+			if instruction.NextState == TuringMachineHalt {
+				next = "[*]"
+			}
+			fmt.Fprintf(&out, "  %s --> %s: %s\n", name, next, turingEdgeLabel(tm.states[name].reads(j), instruction))
+		}
+	}
+	if len(unreachable) > 0 {
+		fmt.Fprintf(&out, "  classDef unreachable stroke-dasharray: 5 5\n")
+		for _, name := range unreachable {
+			fmt.Fprintf(&out, "  class %s unreachable\n", name)
+		}
+	}
+	return out.String()
+}
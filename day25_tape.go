@@ -0,0 +1,65 @@
+package adventofcode2017
+
+// a tape that grows in both directions as the head moves. cells[j] holds
+// the value at position j - origin.
+type turingTape struct {
+	cells  []int
+	origin int
+	counts []int // symbol → how many cells hold it; meaningless for zero
+}
+
+func newTuringTape() turingTape {
+	return turingTape{cells: make([]int, 64), origin: 32, counts: make([]int, 2)}
+}
+
+func (t *turingTape) at(position int) int {
+	index := position + t.origin
+	if index < 0 || index >= len(t.cells) {
+		return 0
+	}
+	return t.cells[index]
+}
+
+func (t *turingTape) count(symbol int) int {
+	if symbol < 0 || symbol >= len(t.counts) {
+		return 0
+	}
+	return t.counts[symbol]
+}
+
+func (t *turingTape) write(position int, value int) {
+	index := position + t.origin
+	if index < 0 || index >= len(t.cells) {
+		t.grow(position)
+		index = position + t.origin
+	}
+	if value >= len(t.counts) {
+		t.growCounts(value)
+	}
+	t.counts[t.cells[index]]--
+	t.counts[value]++
+	t.cells[index] = value
+}
+
+// at least doubles the tape toward position, so a head marching off one
+// end costs amortized constant time per step.
+func (t *turingTape) grow(position int) {
+	lo, hi := -t.origin, len(t.cells)-t.origin // positions [lo, hi) are on the tape
+	for position < lo || position >= hi {
+		size := hi - lo
+		if position < lo {
+			lo -= size
+		} else {
+			hi += size
+		}
+	}
+	cells := make([]int, hi-lo)
+	copy(cells[-t.origin-lo:], t.cells)
+	t.cells, t.origin = cells, -lo
+}
+
+func (t *turingTape) growCounts(symbol int) {
+	counts := make([]int, symbol+1)
+	copy(counts, t.counts)
+	t.counts = counts
+}
@@ -2,8 +2,12 @@ package adventofcode2017_test
 
 import (
 	. "adventofcode2017"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"strings"
 
 	"github.com/MakeNowJust/heredoc"
 	. "github.com/onsi/ginkgo"
@@ -44,17 +48,302 @@ var _ = Describe("Day25", func() {
 				Expect(tm.Position()).To(Equal(0))
 				Expect(tm.StepsRemaining()).To(Equal(6))
 
-				Expect(tm.State("A")).To(Equal(TuringMachineState{[2]TuringMachineInstruction{
+				Expect(tm.State("A")).To(Equal(TuringMachineState{[]TuringMachineInstruction{
 					TuringMachineInstruction{Write: 1, Move: TmRight, NextState: TuringMachineStateName("B")},
 					TuringMachineInstruction{Write: 0, Move: TmLeft, NextState: TuringMachineStateName("B")},
 				}}))
-				Expect(tm.State("B")).To(Equal(TuringMachineState{[2]TuringMachineInstruction{
+				Expect(tm.State("B")).To(Equal(TuringMachineState{[]TuringMachineInstruction{
 					TuringMachineInstruction{Write: 1, Move: TmLeft, NextState: TuringMachineStateName("A")},
 					TuringMachineInstruction{Write: 1, Move: TmRight, NextState: TuringMachineStateName("A")},
 				}}))
 			})
 		})
 
+		Describe("Blueprint()", func() {
+			It("writes the blueprint back out", func() {
+				Expect(NewTuringMachine(testInput).Blueprint()).To(Equal(testInput))
+			})
+
+			It("describes the machine as defined, not as run", func() {
+				tm := NewTuringMachine(testInput)
+				tm.Step()
+				Expect(tm.Blueprint()).To(Equal(testInput))
+			})
+
+			It("round trips the puzzle input", func() {
+				rawData, _ := ioutil.ReadFile("day25.txt")
+				Expect(NewTuringMachine(string(rawData)).Blueprint()).To(Equal(string(rawData)))
+			})
+
+			It("implements encoding.TextMarshaler", func() {
+				text, err := NewTuringMachine(testInput).MarshalText()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(text)).To(Equal(testInput))
+			})
+
+			It("writes out generated machines", func() {
+				tm := NewEmptyTuringMachine("A", 6)
+				tm.DefineState("A", TuringMachineState{[]TuringMachineInstruction{
+					TuringMachineInstruction{Write: 1, Move: TmRight, NextState: TuringMachineStateName("B")},
+					TuringMachineInstruction{Write: 0, Move: TmLeft, NextState: TuringMachineStateName("B")},
+				}})
+				tm.DefineState("B", TuringMachineState{[]TuringMachineInstruction{
+					TuringMachineInstruction{Write: 1, Move: TmLeft, NextState: TuringMachineStateName("A")},
+					TuringMachineInstruction{Write: 1, Move: TmRight, NextState: TuringMachineStateName("A")},
+				}})
+				Expect(tm.Blueprint()).To(Equal(testInput))
+
+				tm.Run()
+				Expect(tm.Checksum()).To(Equal(3))
+			})
+
+			It("writes out edited machines", func() {
+				tm := NewTuringMachine(testInput)
+				tm.DefineState("A", TuringMachineState{[]TuringMachineInstruction{
+					TuringMachineInstruction{Write: 1, Move: TmLeft, NextState: TuringMachineStateName("A")},
+					TuringMachineInstruction{Write: 1, Move: TmLeft, NextState: TuringMachineStateName("A")},
+				}})
+				edited := NewTuringMachine(tm.Blueprint())
+				Expect(edited.State("A")).To(Equal(tm.State("A")))
+				Expect(edited.Blueprint()).To(Equal(strings.Replace(testInput, heredoc.Doc(`
+					In state A:
+					  If the current value is 0:
+					    - Write the value 1.
+					    - Move one slot to the right.
+					    - Continue with state B.
+					  If the current value is 1:
+					    - Write the value 0.
+					    - Move one slot to the left.
+					    - Continue with state B.
+				`), heredoc.Doc(`
+					In state A:
+					  If the current value is 0:
+					    - Write the value 1.
+					    - Move one slot to the left.
+					    - Continue with state A.
+					  If the current value is 1:
+					    - Write the value 1.
+					    - Move one slot to the left.
+					    - Continue with state A.
+				`), 1)))
+
+				edited.Run()
+				Expect(edited.Position()).To(Equal(-6))
+			})
+		})
+
+		Describe("diagrams", func() {
+			// C is never reached, and B can halt
+			var sketch = heredoc.Doc(`
+				Begin in state A.
+				Perform a diagnostic checksum after 6 steps.
+
+				In state A:
+				  If the current value is 0:
+				    - Write the value 1.
+				    - Move one slot to the right.
+				    - Continue with state B.
+
+				In state B:
+				  If the current value is 0:
+				    - Write the value 1.
+				    - Stay in place.
+				    - Continue with state A.
+				  If the current value is 1:
+				    - Write the value 0.
+				    - Move one slot to the left.
+				    - Halt.
+
+				In state C:
+				  If the current value is 0:
+				    - Write the value 1.
+				    - Move one slot to the left.
+				    - Continue with state A.
+			`)
+
+			Describe("Dot()", func() {
+				It("draws each branch as an edge", func() {
+					Expect(NewTuringMachine(testInput).Dot()).To(Equal(heredoc.Doc(`
+						digraph turing {
+						  rankdir=LR;
+						  start [shape=point];
+						  "A" [shape=circle];
+						  "B" [shape=circle];
+						  start -> "A";
+						  "A" -> "B" [label="0/1,R"];
+						  "A" -> "B" [label="1/0,L"];
+						  "B" -> "A" [label="0/1,L"];
+						  "B" -> "A" [label="1/1,R"];
+						}
+					`)))
+				})
+
+				It("marks unreachable states and halting", func() {
+					Expect(NewTuringMachine(sketch).Dot()).To(Equal(heredoc.Doc(`
+						digraph turing {
+						  rankdir=LR;
+						  start [shape=point];
+						  "A" [shape=circle];
+						  "B" [shape=circle];
+						  "C" [shape=circle, style=dashed, label="C (unreachable)"];
+						  start -> "A";
+						  "A" -> "B" [label="0/1,R"];
+						  "B" -> "A" [label="0/1,S"];
+						  "B" -> "HALT" [label="1/0,L"];
+						  "C" -> "A" [label="0/1,L"];
+						  "HALT" [shape=doublecircle];
+						}
+					`)))
+				})
+			})
+
+			Describe("Mermaid()", func() {
+				It("draws each branch as a transition", func() {
+					Expect(NewTuringMachine(testInput).Mermaid()).To(Equal(heredoc.Doc(`
+						stateDiagram-v2
+						  [*] --> A
+						  A --> B: 0/1,R
+						  A --> B: 1/0,L
+						  B --> A: 0/1,L
+						  B --> A: 1/1,R
+					`)))
+				})
+
+				It("marks unreachable states and halting", func() {
+					Expect(NewTuringMachine(sketch).Mermaid()).To(Equal(heredoc.Doc(`
+						stateDiagram-v2
+						  [*] --> A
+						  A --> B: 0/1,R
+						  B --> A: 0/1,S
+						  B --> [*]: 1/0,L
+						  C --> A: 0/1,L
+						  classDef unreachable stroke-dasharray: 5 5
+						  class C unreachable
+					`)))
+				})
+			})
+		})
+
+		Describe("checkpoints", func() {
+			It("saves a machine mid-run and resumes it", func() {
+				tm := NewTuringMachine(testInput)
+				tm.Step()
+				tm.Step()
+				tm.Step()
+
+				var saved bytes.Buffer
+				Expect(tm.SaveCheckpoint(&saved)).To(Succeed())
+
+				var fields map[string]interface{}
+				Expect(json.Unmarshal(saved.Bytes(), &fields)).To(Succeed())
+				Expect(fields).To(HaveKeyWithValue("blueprint", testInput))
+				Expect(fields).To(HaveKeyWithValue("state", "B"))
+				Expect(fields).To(HaveKeyWithValue("position", -1.0))
+				Expect(fields).To(HaveKeyWithValue("steps_remaining", 3.0))
+				Expect(fields).To(HaveKeyWithValue("tape", map[string]interface{}{"1": 1.0}))
+
+				resumed, err := LoadTuringMachine(&saved)
+				Expect(err).NotTo(HaveOccurred())
+				tm.Run()
+				resumed.Run()
+				Expect(resumed.NextState()).To(Equal(tm.NextState()))
+				Expect(resumed.Position()).To(Equal(tm.Position()))
+				Expect(resumed.StepsRemaining()).To(Equal(0))
+				Expect(resumed.TapeWindow(-5, 5)).To(Equal(tm.TapeWindow(-5, 5)))
+				Expect(resumed.Checksum()).To(Equal(3))
+			})
+
+			It("resumes a halted machine", func() {
+				tm := NewTuringMachine(strings.Replace(testInput, "Continue with state A.", "Halt.", 1))
+				tm.Run()
+				Expect(tm.Halted()).To(BeTrue())
+
+				var saved bytes.Buffer
+				Expect(tm.SaveCheckpoint(&saved)).To(Succeed())
+				resumed, err := LoadTuringMachine(&saved)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resumed.Halted()).To(BeTrue())
+				Expect(resumed.Checksum()).To(Equal(tm.Checksum()))
+			})
+
+			It("reports bad checkpoints", func() {
+				_, err := LoadTuringMachine(strings.NewReader("{"))
+				Expect(err).To(HaveOccurred())
+
+				_, err = LoadTuringMachine(strings.NewReader(`{"blueprint": "nonsense"}`))
+				Expect(err).To(MatchError(HavePrefix("bad blueprint in checkpoint")))
+
+				checkpoint, _ := json.Marshal(map[string]interface{}{"blueprint": testInput, "state": "Q"})
+				_, err = LoadTuringMachine(bytes.NewReader(checkpoint))
+				Expect(err).To(MatchError("checkpoint is in unknown state Q"))
+			})
+
+			It("rejects cells the machine couldn't have reached", func() {
+				load := func(fields map[string]interface{}) error {
+					checkpoint := map[string]interface{}{"blueprint": testInput, "state": "A", "steps_remaining": 3}
+					for name, value := range fields {
+						checkpoint[name] = value
+					}
+					data, _ := json.Marshal(checkpoint)
+					_, err := LoadTuringMachine(bytes.NewReader(data))
+					return err
+				}
+
+				Expect(load(map[string]interface{}{"position": -3, "tape": map[string]int{"3": 1, "-2": 1}})).To(Succeed())
+				Expect(load(map[string]interface{}{"position": 1000000000000})).
+					To(MatchError("checkpoint has the head at 1000000000000, more than 3 steps from the start"))
+				Expect(load(map[string]interface{}{"tape": map[string]int{"-1000000000000": 1}})).
+					To(MatchError("checkpoint has the value 1 at -1000000000000, more than 3 steps from the start"))
+				Expect(load(map[string]interface{}{"steps_remaining": 7})).
+					To(MatchError("checkpoint has 7 steps remaining, more than the blueprint's 6"))
+				Expect(load(map[string]interface{}{"tape": map[string]int{"9": 1}, "loaded": 10})).To(Succeed())
+				Expect(load(map[string]interface{}{"tape": map[string]int{"10": 1}, "loaded": 10})).
+					To(MatchError("checkpoint has the value 1 at 10, more than 3 steps from the start"))
+				Expect(load(map[string]interface{}{"loaded": -1})).To(MatchError("checkpoint has -1 loaded cells"))
+			})
+
+			It("resumes a machine whose input is longer than the steps it has taken", func() {
+				for _, steps := range []int{0, 2} {
+					tm := NewTuringMachine(testInput)
+					tm.LoadTape(0, []int{1, 1, 1, 1, 1})
+					for j := 0; j < steps; j++ {
+						tm.Step()
+					}
+
+					var saved bytes.Buffer
+					Expect(tm.SaveCheckpoint(&saved)).To(Succeed())
+					resumed, err := LoadTuringMachine(&saved)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(resumed.TapeWindow(-2, 6)).To(Equal(tm.TapeWindow(-2, 6)))
+
+					tm.Run()
+					resumed.Run()
+					Expect(resumed.TapeWindow(-8, 8)).To(Equal(tm.TapeWindow(-8, 8)))
+					Expect(resumed.Checksum()).To(Equal(tm.Checksum()))
+
+					// and again, from the resumed machine
+					saved.Reset()
+					Expect(resumed.SaveCheckpoint(&saved)).To(Succeed())
+					_, err = LoadTuringMachine(&saved)
+					Expect(err).NotTo(HaveOccurred())
+				}
+			})
+		})
+
+		Describe("TapeWindow()", func() {
+			It("renders part of the tape with the head marked", func() {
+				tm := NewTuringMachine(testInput)
+				Expect(tm.TapeWindow(-2, 2)).To(Equal("..00[0]00.."))
+				tm.Run()
+				Expect(tm.TapeWindow(-3, 2)).To(Equal("..011[0]10.."))
+				Expect(tm.TapeWindow(-2, -1)).To(Equal("..11.."))
+			})
+
+			It("rejects an empty window", func() {
+				Expect(func() { NewTuringMachine(testInput).TapeWindow(1, 0) }).To(Panic())
+			})
+		})
+
 		Describe("Step()", func() {
 			It("moves through the current state into the next state", func() {
 				tm := NewTuringMachine(testInput)
@@ -127,6 +416,576 @@ var _ = Describe("Day25", func() {
 		})
 	})
 
+	Describe("halting", func() {
+		var busyBeaver = heredoc.Doc(`
+			Begin in state A.
+			Perform a diagnostic checksum after 100 steps.
+
+			In state A:
+			  If the current value is 0:
+			    - Write the value 1.
+			    - Move one slot to the right.
+			    - Continue with state B.
+			  If the current value is 1:
+			    - Write the value 1.
+			    - Move one slot to the left.
+			    - Continue with state B.
+
+			In state B:
+			  If the current value is 0:
+			    - Write the value 1.
+			    - Move one slot to the left.
+			    - Continue with state A.
+			  If the current value is 1:
+			    - Write the value 1.
+			    - Move one slot to the right.
+			    - Halt.
+		`)
+
+		It("stops early and reports the steps taken", func() {
+			tm := NewTuringMachine(busyBeaver)
+			Expect(tm.Halted()).To(BeFalse())
+			Expect(tm.Run()).To(Equal(6))
+			Expect(tm.Halted()).To(BeTrue())
+			Expect(tm.NextState()).To(Equal("HALT"))
+			Expect(tm.StepsRemaining()).To(Equal(94))
+			Expect(tm.Checksum()).To(Equal(4))
+		})
+
+		It("ignores steps after halting", func() {
+			tm := NewTuringMachine(busyBeaver)
+			tm.Run()
+			tm.Step()
+			Expect(tm.StepsRemaining()).To(Equal(94))
+			Expect(tm.Position()).To(Equal(0))
+			Expect(tm.Run()).To(Equal(0))
+		})
+
+		It("halts on continuing with state HALT", func() {
+			tm := NewTuringMachine(strings.Replace(busyBeaver, "Halt.", "Continue with state HALT.", 1))
+			Expect(tm.State("B").Branch[1].NextState).To(Equal(TuringMachineHalt))
+			Expect(tm.Run()).To(Equal(6))
+			Expect(tm.Blueprint()).To(Equal(busyBeaver))
+		})
+
+		It("reports the full step count when the machine doesn't halt", func() {
+			tm := NewTuringMachine(strings.Replace(busyBeaver, "Halt.", "Continue with state A.", 1))
+			Expect(tm.Run()).To(Equal(100))
+			Expect(tm.Halted()).To(BeFalse())
+		})
+
+		It("solves the three-state busy beaver", func() {
+			tm := NewEmptyTuringMachine("A", 1000)
+			for name, branch := range map[string][]TuringMachineInstruction{
+				"A": {{Write: 1, Move: TmRight, NextState: "B"}, {Write: 1, Move: TmRight, NextState: TuringMachineHalt}},
+				"B": {{Write: 0, Move: TmRight, NextState: "C"}, {Write: 1, Move: TmRight, NextState: "B"}},
+				"C": {{Write: 1, Move: TmLeft, NextState: "C"}, {Write: 1, Move: TmLeft, NextState: "A"}},
+			} {
+				tm.DefineState(name, TuringMachineState{branch})
+			}
+			Expect(tm.Run()).To(Equal(14))
+			Expect(tm.Checksum()).To(Equal(6))
+		})
+
+		It("stays in place", func() {
+			tm := NewTuringMachine(heredoc.Doc(`
+				Begin in state A.
+				Perform a diagnostic checksum after 10 steps.
+
+				In state A:
+				  If the current value is 0:
+				    - Write the value 1.
+				    - Stay in place.
+				    - Continue with state A.
+				  If the current value is 1:
+				    - Write the value 2.
+				    - Stay in place.
+				    - Halt.
+			`))
+			Expect(tm.State("A").Branch[0].Move).To(Equal(TmStay))
+			Expect(tm.Run()).To(Equal(2))
+			Expect(tm.Position()).To(Equal(0))
+			Expect(tm.TapeAt(0)).To(Equal(2))
+			Expect(NewTuringMachine(tm.Blueprint()).Blueprint()).To(Equal(tm.Blueprint()))
+		})
+	})
+
+	Describe("multi-symbol alphabets", func() {
+		var threeSymbols = heredoc.Doc(`
+			Begin in state A.
+			Perform a diagnostic checksum after 5 steps.
+
+			In state A:
+			  If the current value is 0:
+			    - Write the value 2.
+			    - Move one slot to the right.
+			    - Continue with state B.
+			  If the current value is 1:
+			    - Write the value 0.
+			    - Move one slot to the right.
+			    - Continue with state A.
+			  If the current value is 2:
+			    - Write the value 1.
+			    - Move one slot to the left.
+			    - Continue with state A.
+
+			In state B:
+			  If the current value is 0:
+			    - Write the value 1.
+			    - Move one slot to the left.
+			    - Continue with state A.
+			  If the current value is 1:
+			    - Write the value 2.
+			    - Move one slot to the right.
+			    - Continue with state B.
+		`)
+
+		It("parses any number of clauses", func() {
+			tm := NewTuringMachine(threeSymbols)
+			Expect(tm.State("A").Branch).To(HaveLen(3))
+			Expect(tm.State("B").Branch).To(HaveLen(2))
+			Expect(tm.Blueprint()).To(Equal(threeSymbols))
+		})
+
+		It("counts any symbol", func() {
+			tm := NewTuringMachine(threeSymbols)
+			tm.Run()
+			Expect(tm.Position()).To(Equal(1))
+			Expect(tm.Checksum()).To(Equal(1))
+			Expect(tm.ChecksumOf(2)).To(Equal(2))
+			Expect(tm.ChecksumOf(7)).To(Equal(0))
+			_, err := tm.ChecksumOf(0)
+			Expect(err).To(MatchError("the tape holds infinitely many zeros"))
+			Expect(tm.Histogram()).To(Equal(map[int]int{1: 1, 2: 2}))
+		})
+
+		It("counts the same when stepping", func() {
+			tm := NewTuringMachine(threeSymbols)
+			for tm.StepsRemaining() > 0 {
+				tm.Step()
+			}
+			Expect(tm.Histogram()).To(Equal(map[int]int{1: 1, 2: 2}))
+		})
+
+		It("requires clauses in order", func() {
+			Expect(func() {
+				NewTuringMachine(strings.Replace(threeSymbols, "If the current value is 1:", "If the current value is 2:", 1))
+			}).To(Panic())
+		})
+
+		It("complains about a value a state has no instruction for", func() {
+			tm := NewTuringMachine(threeSymbols)
+			tm.DefineState("B", TuringMachineState{[]TuringMachineInstruction{
+				TuringMachineInstruction{Write: 1, Move: TmLeft, NextState: TuringMachineStateName("A")},
+			}})
+			Expect(func() { tm.Run() }).To(PanicWith("state B has no instruction for the value 1"))
+		})
+
+		It("complains on reaching a state that was never defined", func() {
+			tm := NewEmptyTuringMachine("A", 10)
+			tm.DefineState("A", TuringMachineState{[]TuringMachineInstruction{
+				TuringMachineInstruction{Write: 1, Move: TmRight, NextState: TuringMachineStateName("B")},
+			}})
+			Expect(func() { tm.Run() }).To(PanicWith("state B has no instruction for the value 0"))
+		})
+	})
+
+	Describe("dense tape", func() {
+		marcher := func(direction string) string {
+			return heredoc.Doc(`
+				Begin in state A.
+				Perform a diagnostic checksum after 1000 steps.
+
+				In state A:
+				  If the current value is 0:
+				    - Write the value 1.
+				    - Move one slot to the ` + direction + `.
+				    - Continue with state A.
+				  If the current value is 1:
+				    - Write the value 0.
+				    - Move one slot to the ` + direction + `.
+				    - Continue with state A.
+			`)
+		}
+
+		It("grows to the left", func() {
+			tm := NewTuringMachine(marcher("left"))
+			tm.Run()
+			Expect(tm.Position()).To(Equal(-1000))
+			Expect(tm.Checksum()).To(Equal(1000))
+			for position := -999; position <= 0; position++ {
+				Expect(tm.TapeAt(position)).To(Equal(1))
+			}
+			Expect(tm.TapeAt(-1000)).To(Equal(0))
+			Expect(tm.TapeAt(1)).To(Equal(0))
+		})
+
+		It("grows to the right", func() {
+			tm := NewTuringMachine(marcher("right"))
+			for j := 0; j < 500; j++ {
+				tm.Step()
+			}
+			tm.Run()
+			Expect(tm.Position()).To(Equal(1000))
+			Expect(tm.Checksum()).To(Equal(1000))
+			Expect(tm.TapeAt(999)).To(Equal(1))
+			Expect(tm.TapeAt(1000)).To(Equal(0))
+		})
+
+		It("runs the same as stepping", func() {
+			rawData, _ := ioutil.ReadFile("day25.txt")
+			blueprint := strings.Replace(string(rawData), "12261543 steps", "100000 steps", 1)
+
+			running := NewTuringMachine(blueprint)
+			running.Run()
+			stepping := NewTuringMachine(blueprint)
+			for stepping.StepsRemaining() > 0 {
+				stepping.Step()
+			}
+
+			Expect(running.NextState()).To(Equal(stepping.NextState()))
+			Expect(running.Position()).To(Equal(stepping.Position()))
+			Expect(running.Checksum()).To(Equal(stepping.Checksum()))
+			for position := -10000; position <= 10000; position++ {
+				Expect(running.TapeAt(position)).To(Equal(stepping.TapeAt(position)))
+			}
+		})
+	})
+
+	Describe("validation", func() {
+		define := func(tm *TuringMachine, states map[string][]TuringMachineInstruction) *TuringMachine {
+			for _, name := range []string{"A", "B", "C", "D"} {
+				if branch, ok := states[name]; ok {
+					tm.DefineState(name, TuringMachineState{branch})
+				}
+			}
+			return tm
+		}
+
+		It("rejects references to undefined states", func() {
+			tm := define(NewEmptyTuringMachine("A", 10), map[string][]TuringMachineInstruction{
+				"A": {{Write: 1, Move: TmRight, NextState: "B"}, {Write: 1, Move: TmLeft, NextState: "C"}},
+				"C": {{Write: 1, Move: TmLeft, NextState: "D"}},
+			})
+			_, err := tm.Validate()
+			Expect(err).To(MatchError("state A continues with undefined state B on a 0; state C continues with undefined state D on a 0"))
+			Expect(func() { NewTuringMachine(tm.Blueprint()) }).To(Panic())
+		})
+
+		It("rejects an undefined start state", func() {
+			tm := define(NewEmptyTuringMachine("Z", 10), map[string][]TuringMachineInstruction{
+				"A": {{Write: 1, Move: TmRight, NextState: "A"}},
+			})
+			_, err := tm.Validate()
+			Expect(err).To(MatchError("start state Z is not defined"))
+			Expect(func() { NewTuringMachine(tm.Blueprint()) }).To(Panic())
+		})
+
+		It("warns about likely mistakes while parsing", func() {
+			tm := define(NewEmptyTuringMachine("A", 10), map[string][]TuringMachineInstruction{
+				"A": {{Write: 1, Move: TmRight, NextState: "B"}, {Write: 0, Move: TmLeft, NextState: "A"}},
+				"B": {{Write: 0, Move: TmStay, NextState: "C"}, {Write: 1, Move: TmRight, NextState: TuringMachineHalt}},
+				"C": {{Write: 0, Move: TmStay, NextState: "B"}},
+				"D": {{Write: 1, Move: TmRight, NextState: "D"}},
+			})
+			Expect(NewTuringMachine(tm.Blueprint()).Warnings()).To(Equal([]string{
+				"state D is unreachable from A",
+				"state C never writes a 1",
+				"stays in place forever: B on a 0, C on a 0",
+				"runs right forever on blank tape: D",
+			}))
+		})
+
+		It("finds nothing wrong with a sound blueprint", func() {
+			tm := define(NewEmptyTuringMachine("A", 10), map[string][]TuringMachineInstruction{
+				"A": {{Write: 1, Move: TmRight, NextState: "B"}, {Write: 0, Move: TmLeft, NextState: "B"}},
+				"B": {{Write: 1, Move: TmLeft, NextState: "A"}, {Write: 1, Move: TmRight, NextState: TuringMachineHalt}},
+			})
+			warnings, err := tm.Validate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+			Expect(NewTuringMachine(tm.Blueprint()).Warnings()).To(BeEmpty())
+		})
+	})
+
+	Describe("multiple tapes", func() {
+		// copies the ones on tape 0 onto tape 1, then walks tape 1 back
+		copier := heredoc.Doc(`
+			Begin in state A.
+			Perform a diagnostic checksum after 100 steps.
+			Use 2 tapes.
+
+			In state A:
+			  If the current values are 1, 0:
+			    - Write the values 1, 1.
+			    - Move the heads right, right.
+			    - Continue with state A.
+			  If the current values are 0, 0:
+			    - Write the values 0, 0.
+			    - Move the heads stay, left.
+			    - Continue with state B.
+
+			In state B:
+			  If the current values are 0, 1:
+			    - Write the values 0, 1.
+			    - Move the heads stay, left.
+			    - Continue with state B.
+			  If the current values are 0, 0:
+			    - Write the values 0, 0.
+			    - Move the heads stay, right.
+			    - Halt.
+		`)
+
+		It("reads and writes every tape, and moves every head", func() {
+			tm := NewTuringMachine(copier)
+			Expect(tm.Tapes()).To(Equal(2))
+			tm.LoadTape(0, []int{1, 1, 1})
+
+			Expect(tm.Run()).To(Equal(8))
+			Expect(tm.Halted()).To(BeTrue())
+			Expect(tm.StepsRemaining()).To(Equal(92))
+			Expect(tm.PositionOf(0)).To(Equal(3))
+			Expect(tm.PositionOf(1)).To(Equal(0))
+			for position := 0; position < 3; position++ {
+				Expect(tm.TapeOf(1, position)).To(Equal(1))
+			}
+			Expect(tm.TapeOf(1, 3)).To(Equal(0))
+			Expect(tm.Checksum()).To(Equal(6))
+		})
+
+		It("steps the same way it runs", func() {
+			tm := NewTuringMachine(copier)
+			tm.LoadTape(0, []int{1, 1, 1})
+			for !tm.Halted() {
+				tm.Step()
+			}
+			Expect(tm.StepsRemaining()).To(Equal(92))
+			Expect(tm.PositionOf(0)).To(Equal(3))
+			Expect(tm.Checksum()).To(Equal(6))
+		})
+
+		It("writes the blueprint back out", func() {
+			tm := NewTuringMachine(copier)
+			Expect(tm.Blueprint()).To(Equal(copier))
+			Expect(NewTuringMachine(tm.Blueprint()).Blueprint()).To(Equal(copier))
+		})
+
+		It("resumes from a checkpoint", func() {
+			tm := NewTuringMachine(copier)
+			tm.LoadTape(0, []int{1, 1, 1})
+			for j := 0; j < 4; j++ {
+				tm.Step()
+			}
+
+			var checkpoint bytes.Buffer
+			Expect(tm.SaveCheckpoint(&checkpoint)).To(Succeed())
+			resumed, err := LoadTuringMachine(&checkpoint)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resumed.PositionOf(1)).To(Equal(tm.PositionOf(1)))
+
+			Expect(resumed.Run()).To(Equal(tm.Run()))
+			Expect(resumed.PositionOf(0)).To(Equal(3))
+			Expect(resumed.PositionOf(1)).To(Equal(0))
+			Expect(resumed.Checksum()).To(Equal(6))
+		})
+
+		It("rejects a checkpoint with the wrong number of tapes", func() {
+			var checkpoint bytes.Buffer
+			Expect(NewTuringMachine(copier).SaveCheckpoint(&checkpoint)).To(Succeed())
+			single := strings.Replace(checkpoint.String(), `\nUse 2 tapes.`, "", 1)
+			_, err := LoadTuringMachine(strings.NewReader(single))
+			Expect(err).To(MatchError(HavePrefix("bad blueprint in checkpoint")))
+
+			withoutOthers := strings.Replace(checkpoint.String(), `,"other_tapes":[{"position":0,"tape":{}}]`, "", 1)
+			_, err = LoadTuringMachine(strings.NewReader(withoutOthers))
+			Expect(err).To(MatchError("checkpoint has 1 tapes, but the blueprint uses 2"))
+		})
+
+		It("labels diagram edges with every tape's values and moves", func() {
+			Expect(NewTuringMachine(copier).Mermaid()).To(ContainSubstring("A --> B: 0,0/0,0,SL\n"))
+		})
+
+		It("validates clauses by the values they read", func() {
+			_, err := NewTuringMachine(copier).Validate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(func() {
+				NewTuringMachine(strings.Replace(copier, "Continue with state B", "Continue with state C", 1))
+			}).To(PanicWith("state A continues with undefined state C on 0, 0"))
+
+			spinner := heredoc.Doc(`
+				Begin in state A.
+				Perform a diagnostic checksum after 10 steps.
+				Use 2 tapes.
+
+				In state A:
+				  If the current values are 0, 0:
+				    - Write the values 1, 0.
+				    - Move the heads stay, stay.
+				    - Continue with state A.
+				  If the current values are 1, 0:
+				    - Write the values 0, 0.
+				    - Move the heads stay, stay.
+				    - Continue with state A.
+			`)
+			Expect(NewTuringMachine(spinner).Warnings()).To(Equal([]string{
+				"stays in place forever: A on 0, 0, A on 1, 0",
+			}))
+		})
+
+		It("runs the same with or without acceleration", func() {
+			tm, accelerated := NewTuringMachine(copier), NewTuringMachine(copier)
+			tm.LoadTape(0, []int{1, 1, 1})
+			accelerated.LoadTape(0, []int{1, 1, 1})
+			Expect(accelerated.RunAccelerated(4)).To(Equal(tm.Run()))
+			Expect(accelerated.PositionOf(0)).To(Equal(tm.PositionOf(0)))
+			Expect(accelerated.Checksum()).To(Equal(tm.Checksum()))
+		})
+
+		It("rejects clauses for the wrong number of tapes", func() {
+			Expect(func() {
+				NewTuringMachine(strings.Replace(copier, "Write the values 1, 1.", "Write the value 1.", 1))
+			}).To(Panic())
+			Expect(func() {
+				NewTuringMachine(strings.Replace(copier, "right, right", "right", 1))
+			}).To(Panic())
+			Expect(func() {
+				NewTuringMachine(strings.Replace(copier, "If the current values are 0, 1:", "If the current values are 0, 0:", 1))
+			}).To(PanicWith("state B has two clauses for the values 0, 0"))
+		})
+
+		It("only compiles the combinations of values it has clauses for", func() {
+			// a dense table of 10^7 combinations per state would take
+			// hundreds of megabytes
+			wide := heredoc.Doc(`
+				Begin in state A.
+				Perform a diagnostic checksum after 5 steps.
+				Use 7 tapes.
+
+				In state A:
+				  If the current values are 0, 0, 0, 0, 0, 0, 0:
+				    - Write the values 9, 0, 0, 0, 0, 0, 1.
+				    - Move the heads right, stay, stay, stay, stay, stay, right.
+				    - Continue with state A.
+			`)
+			tm := NewTuringMachine(wide)
+			Expect(tm.Run()).To(Equal(5))
+			Expect(tm.ChecksumOf(9)).To(Equal(5))
+			Expect(tm.Checksum()).To(Equal(5))
+		})
+
+		It("complains about combinations of values it has no clause for", func() {
+			tm := NewTuringMachine(copier)
+			tm.LoadTape(0, []int{1, 0, 1})
+			tm.LoadTape(1, []int{1})
+			Expect(func() { tm.Run() }).To(PanicWith("state A has no instruction for the values 1, 1"))
+		})
+	})
+
+	Describe("RunAccelerated()", func() {
+		expectSameMachine := func(actual, expected *TuringMachine) {
+			Expect(actual.NextState()).To(Equal(expected.NextState()))
+			Expect(actual.Position()).To(Equal(expected.Position()))
+			Expect(actual.StepsRemaining()).To(Equal(expected.StepsRemaining()))
+			Expect(actual.Histogram()).To(Equal(expected.Histogram()))
+			for position := expected.Position() - 3000; position <= expected.Position()+3000; position++ {
+				if actual.TapeAt(position) != expected.TapeAt(position) {
+					Fail(fmt.Sprintf("tapes differ at %d", position))
+				}
+			}
+		}
+
+		// sweeps back and forth, growing the run of ones by one at each end
+		bouncer := heredoc.Doc(`
+			Begin in state A.
+			Perform a diagnostic checksum after 1000000 steps.
+
+			In state A:
+			  If the current value is 0:
+			    - Write the value 1.
+			    - Move one slot to the left.
+			    - Continue with state B.
+			  If the current value is 1:
+			    - Write the value 1.
+			    - Move one slot to the right.
+			    - Continue with state A.
+
+			In state B:
+			  If the current value is 0:
+			    - Write the value 1.
+			    - Move one slot to the right.
+			    - Continue with state A.
+			  If the current value is 1:
+			    - Write the value 1.
+			    - Move one slot to the left.
+			    - Continue with state B.
+		`)
+
+		It("agrees with Run()", func() {
+			rawData, _ := ioutil.ReadFile("day25.txt")
+			blueprints := []string{
+				bouncer,
+				strings.Replace(string(rawData), "12261543 steps", "1000000 steps", 1),
+			}
+			for _, blueprint := range blueprints {
+				expected := NewTuringMachine(blueprint)
+				steps := expected.Run()
+				for _, blockSize := range []int{1, 2, 3, 5, 8} {
+					actual := NewTuringMachine(blueprint)
+					Expect(actual.RunAccelerated(blockSize)).To(Equal(steps))
+					expectSameMachine(actual, expected)
+				}
+			}
+		})
+
+		It("picks up where Step() left off", func() {
+			expected := NewTuringMachine(bouncer)
+			actual := NewTuringMachine(bouncer)
+			for j := 0; j < 777; j++ {
+				expected.Step()
+				actual.Step()
+			}
+			expected.Run()
+			actual.RunAccelerated(4)
+			expectSameMachine(actual, expected)
+		})
+
+		It("stops when the machine halts", func() {
+			tm := NewEmptyTuringMachine("A", 1000)
+			for name, branch := range map[string][]TuringMachineInstruction{
+				"A": {{Write: 1, Move: TmRight, NextState: "B"}, {Write: 1, Move: TmRight, NextState: TuringMachineHalt}},
+				"B": {{Write: 0, Move: TmRight, NextState: "C"}, {Write: 1, Move: TmRight, NextState: "B"}},
+				"C": {{Write: 1, Move: TmLeft, NextState: "C"}, {Write: 1, Move: TmLeft, NextState: "A"}},
+			} {
+				tm.DefineState(name, TuringMachineState{branch})
+			}
+			blueprint := tm.Blueprint()
+
+			expected := NewTuringMachine(blueprint)
+			expected.Run()
+			for _, blockSize := range []int{1, 2, 4} {
+				actual := NewTuringMachine(blueprint)
+				Expect(actual.RunAccelerated(blockSize)).To(Equal(14))
+				Expect(actual.Halted()).To(BeTrue())
+				expectSameMachine(actual, expected)
+			}
+		})
+
+		It("makes a billion steps practical", func() {
+			blueprint := strings.Replace(bouncer, "1000000 steps", "1000000000 steps", 1)
+			var checksums []int
+			for _, blockSize := range []int{1, 3, 8} {
+				tm := NewTuringMachine(blueprint)
+				Expect(tm.RunAccelerated(blockSize)).To(Equal(1000000000))
+				checksums = append(checksums, tm.Checksum())
+			}
+			Expect(checksums).To(Equal([]int{44721, 44721, 44721}))
+		})
+
+		It("rejects an empty block size", func() {
+			Expect(func() { NewTuringMachine(bouncer).RunAccelerated(0) }).To(Panic())
+		})
+	})
+
 	Describe("puzzle", func() {
 		rawData, _ := ioutil.ReadFile("day25.txt")
 		blueprint := string(rawData)
@@ -137,5 +996,35 @@ var _ = Describe("Day25", func() {
 			checksum := tm.Checksum()
 			fmt.Printf("d25 s1: checksum is %d\n", checksum)
 		})
+
+		It("solves star 1 across a checkpoint on disk", func() {
+			tm := NewTuringMachine(blueprint)
+			for half := tm.StepsRemaining() / 2; tm.StepsRemaining() > half; {
+				tm.Step()
+			}
+
+			file, _ := ioutil.TempFile("", "day25")
+			defer os.Remove(file.Name())
+			Expect(tm.SaveCheckpoint(file)).To(Succeed())
+			file.Seek(0, 0)
+			resumed, err := LoadTuringMachine(file)
+			file.Close()
+			Expect(err).NotTo(HaveOccurred())
+
+			resumed.Run()
+			fmt.Printf("d25 s1 resumed: checksum is %d\n", resumed.Checksum())
+		})
+
+		It("validates the blueprint", func() {
+			warnings, err := NewTuringMachine(blueprint).Validate()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+		})
+
+		It("solves star 1 in macro steps", func() {
+			tm := NewTuringMachine(blueprint)
+			tm.RunAccelerated(4)
+			fmt.Printf("d25 s1 accelerated: checksum is %d\n", tm.Checksum())
+		})
 	})
 })
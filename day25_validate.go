@@ -0,0 +1,177 @@
+package adventofcode2017
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// checks the blueprint. references to states that aren't defined are
+// errors; the warnings are for things that are legal but probably
+// mistakes: unreachable states, states that never write a 1, and loops
+// that can never halt.
+func (tm *TuringMachine) Validate() (warnings []string, err error) {
+	var problems []string
+	if _, ok := tm.states[tm.begin]; !ok {
+		problems = append(problems, fmt.Sprintf("start state %s is not defined", tm.begin))
+	}
+	for _, name := range tm.order {
+		for j, instruction := range tm.states[name].Branch {
+			if _, ok := tm.states[instruction.NextState]; !ok && instruction.NextState != TuringMachineHalt {
+				problems = append(problems, fmt.Sprintf("state %s continues with undefined state %s %s", name, instruction.NextState, tm.onValues(tm.states[name].reads(j))))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+
+	reachable := tm.reachable()
+	for _, name := range tm.order {
+		if !reachable[name] {
+			warnings = append(warnings, fmt.Sprintf("state %s is unreachable from %s", name, tm.begin))
+		}
+	}
+
+	for _, name := range tm.order {
+		writesOne := false
+		for _, instruction := range tm.states[name].Branch {
+			for _, action := range instruction.actions() {
+				writesOne = writesOne || action.Write == 1
+			}
+		}
+		if !writesOne {
+			warnings = append(warnings, fmt.Sprintf("state %s never writes a 1", name))
+		}
+	}
+
+	warnings = append(warnings, tm.stayLoops()...)
+	warnings = append(warnings, tm.blankRuns(TmRight, "right")...)
+	warnings = append(warnings, tm.blankRuns(TmLeft, "left")...)
+	return warnings, nil
+}
+
+func (tm *TuringMachine) Warnings() []string {
+	return tm.warnings
+}
+
+// a state about to run one of its clauses.
+type turingNode struct {
+	state  TuringMachineStateName
+	clause int // index into the state's Branch
+}
+
+// `on a 1` with one tape, `on 1, 0` with more.
+func (tm *TuringMachine) onValues(values []int) string {
+	if len(tm.tapes) == 1 {
+		return fmt.Sprintf("on a %d", values[0])
+	}
+	return "on " + joinTuringValues(values)
+}
+
+// finds the cycles in a graph where every node has at most one successor,
+// visiting the starts in order. each cycle is listed from the node where
+// it was first entered.
+func turingCycles(starts []turingNode, next func(turingNode) (turingNode, bool)) [][]turingNode {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	status := make(map[turingNode]int)
+	var cycles [][]turingNode
+	for _, start := range starts {
+		var path []turingNode
+		node, ok := start, true
+		for ok && status[node] == unvisited {
+			status[node] = visiting
+			path = append(path, node)
+			node, ok = next(node)
+		}
+		if ok && status[node] == visiting {
+			for j := range path {
+				if path[j] == node {
+					cycles = append(cycles, path[j:])
+					break
+				}
+			}
+		}
+		for _, visited := range path {
+			status[visited] = done
+		}
+	}
+	return cycles
+}
+
+// staying in place, the next state reads what was just written, so a
+// cycle of stays never moves again.
+func (tm *TuringMachine) stayLoops() []string {
+	var starts []turingNode
+	for _, name := range tm.order {
+		for clause := range tm.states[name].Branch {
+			starts = append(starts, turingNode{name, clause})
+		}
+	}
+	next := func(node turingNode) (turingNode, bool) {
+		instruction := tm.states[node.state].Branch[node.clause]
+		if instruction.NextState == TuringMachineHalt {
+			return turingNode{}, false
+		}
+		actions := instruction.actions()
+		writes := make([]int, len(actions))
+		for j, action := range actions {
+			if action.Move != TmStay {
+				return turingNode{}, false
+			}
+			writes[j] = action.Write
+		}
+		clause, ok := tm.states[instruction.NextState].clauseFor(writes)
+		return turingNode{instruction.NextState, clause}, ok
+	}
+
+	var warnings []string
+	for _, cycle := range turingCycles(starts, next) {
+		steps := make([]string, len(cycle))
+		for j, node := range cycle {
+			steps[j] = fmt.Sprintf("%s %s", node.state, tm.onValues(tm.states[node.state].reads(node.clause)))
+		}
+		warnings = append(warnings, fmt.Sprintf("stays in place forever: %s", strings.Join(steps, ", ")))
+	}
+	return warnings
+}
+
+// past the end of what has been written, the tapes are all zeros, so a
+// cycle of states that read only 0s and move every head the same way
+// never comes back.
+func (tm *TuringMachine) blankRuns(move TuringMachineDirection, direction string) []string {
+	blank := make([]int, len(tm.tapes))
+	var starts []turingNode
+	for _, name := range tm.order {
+		if clause, ok := tm.states[name].clauseFor(blank); ok {
+			starts = append(starts, turingNode{name, clause})
+		}
+	}
+	next := func(node turingNode) (turingNode, bool) {
+		instruction := tm.states[node.state].Branch[node.clause]
+		if instruction.NextState == TuringMachineHalt {
+			return turingNode{}, false
+		}
+		for _, action := range instruction.actions() {
+			if action.Move != move {
+				return turingNode{}, false
+			}
+		}
+		clause, ok := tm.states[instruction.NextState].clauseFor(blank)
+		return turingNode{instruction.NextState, clause}, ok
+	}
+
+	var warnings []string
+	for _, cycle := range turingCycles(starts, next) {
+		names := make([]string, len(cycle))
+		for j, node := range cycle {
+			names[j] = string(node.state)
+		}
+		warnings = append(warnings, fmt.Sprintf("runs %s forever on blank tape: %s", direction, strings.Join(names, ", ")))
+	}
+	return warnings
+}
@@ -0,0 +1,172 @@
+package adventofcode2017_test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type RegisterErrorPolicy int
+
+const (
+	RegisterStopOnError RegisterErrorPolicy = iota // all or nothing
+	RegisterSkipErrors                             // bad lines are skipped
+)
+
+type RegisterDiagnostic struct {
+	line    int // 1-based
+	message string
+}
+
+func (d RegisterDiagnostic) Error() string {
+	return fmt.Sprintf("line %d: %s", d.line, d.message)
+}
+
+func registerDiagnostic(line int, err error) RegisterDiagnostic {
+	return RegisterDiagnostic{line: line, message: strings.TrimPrefix(err.Error(), "error: ")}
+}
+
+// runs every line of the program, reporting what went wrong where. when
+// stopping on error, every line is parsed before any runs, and the
+// registers are only updated if the whole program succeeds. the error is
+// for failures reading the program.
+func (rs RegisterSet) runReader(r io.Reader, policy RegisterErrorPolicy) ([]RegisterDiagnostic, error) {
+	diagnostics := []RegisterDiagnostic{}
+	program := RegisterProgram{}
+	lines := []int{} // program index → line number
+
+	scanner := bufio.NewScanner(r)
+	for jline := 1; scanner.Scan(); jline++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		ri, err := parseRegisterInstruction(scanner.Text())
+		if err != nil {
+			diagnostics = append(diagnostics, registerDiagnostic(jline, err))
+			continue
+		}
+		program = append(program, ri)
+		lines = append(lines, jline)
+	}
+	if err := scanner.Err(); err != nil {
+		return diagnostics, err
+	}
+	if policy == RegisterStopOnError && len(diagnostics) > 0 {
+		return diagnostics, nil
+	}
+
+	target := rs
+	if policy == RegisterStopOnError {
+		target = NewRegisterSet()
+		for name, value := range rs {
+			target[name] = value
+		}
+	}
+
+	for j, ri := range program {
+		if _, err := target.exec(ri); err != nil {
+			diagnostics = append(diagnostics, registerDiagnostic(lines[j], err))
+			if policy == RegisterStopOnError {
+				return diagnostics, nil
+			}
+		}
+	}
+
+	for name, value := range target {
+		rs[name] = value
+	}
+	return diagnostics, nil
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("disk on fire")
+}
+
+var _ = Describe("Day8", func() {
+	Describe("runReader", func() {
+		program := heredoc.Doc(`
+			a inc 5 if b < 1
+			a foo 1 if b < 1
+
+			b inc 99999999999999999999 if a > 1
+			c inc 1 if a <> 5
+			c mod b if a > 1
+			c inc 10 if a > 1
+		`)
+
+		var rs RegisterSet
+
+		BeforeEach(func() {
+			rs = RegisterSet{"z": 26}
+		})
+
+		It("runs a whole program", func() {
+			diagnostics, err := rs.runReader(strings.NewReader("a inc 5 if b < 1\nb dec 2 if a == 5\n"), RegisterStopOnError)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diagnostics).To(BeEmpty())
+			Expect(rs).To(Equal(RegisterSet{"a": 5, "b": -2, "z": 26}))
+		})
+
+		It("reports every parse error, and runs nothing, when stopping on error", func() {
+			diagnostics, _ := rs.runReader(strings.NewReader(program), RegisterStopOnError)
+			Expect(diagnostics).To(Equal([]RegisterDiagnostic{
+				RegisterDiagnostic{line: 2, message: "unrecognized operator `foo`"},
+				RegisterDiagnostic{line: 4, message: "cannot parse `99999999999999999999` as an int"},
+				RegisterDiagnostic{line: 5, message: "unrecognized comparison `<>`"},
+			}))
+			Expect(rs).To(Equal(RegisterSet{"z": 26}))
+		})
+
+		It("leaves the registers alone after a runtime error when stopping on error", func() {
+			diagnostics, _ := rs.runReader(strings.NewReader("a inc 5 if b < 1\nc mod b if a > 1\n"), RegisterStopOnError)
+			Expect(diagnostics).To(Equal([]RegisterDiagnostic{RegisterDiagnostic{line: 2, message: "mod by zero in `c mod b if a > 1`"}}))
+			Expect(rs).To(Equal(RegisterSet{"z": 26}))
+		})
+
+		It("skips bad lines when continuing on error", func() {
+			diagnostics, _ := rs.runReader(strings.NewReader(program), RegisterSkipErrors)
+			Expect(diagnostics).To(HaveLen(4))
+			Expect(diagnostics[3]).To(Equal(RegisterDiagnostic{line: 6, message: "mod by zero in `c mod b if a > 1`"}))
+			Expect(rs).To(Equal(RegisterSet{"a": 5, "b": 0, "c": 10, "z": 26}))
+		})
+
+		It("formats diagnostics with their line number", func() {
+			Expect(RegisterDiagnostic{line: 2, message: "unrecognized operator `foo`"}.Error()).
+				To(Equal("line 2: unrecognized operator `foo`"))
+		})
+
+		It("reports read failures", func() {
+			_, err := rs.runReader(failingReader{}, RegisterSkipErrors)
+			Expect(err).To(MatchError("disk on fire"))
+		})
+	})
+
+	Describe("puzzle", func() {
+		It("runs the puzzle input from a file", func() {
+			file, _ := os.Open("day8.txt")
+			defer file.Close()
+
+			rs := NewRegisterSet()
+			diagnostics, err := rs.runReader(file, RegisterStopOnError)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diagnostics).To(BeEmpty())
+
+			max := 0
+			for _, value := range rs {
+				if value > max {
+					max = value
+				}
+			}
+			fmt.Printf("d8 s1: largest value is %d\n", max)
+		})
+	})
+})
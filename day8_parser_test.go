@@ -0,0 +1,429 @@
+package adventofcode2017_test
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+//
+//  the instruction grammar
+//
+//    instruction := register operator expr "if" cond
+//    cond        := and { "||" and }
+//    and         := not { "&&" not }
+//    not         := "!" not | "(" cond ")" | expr comparison expr
+//    expr        := term { ("+" | "-") term }
+//    term        := factor { "*" factor }
+//    factor      := "-" factor | "(" expr ")" | integer | register
+//
+
+var registerOperators = map[string]bool{"inc": true, "dec": true, "mul": true, "set": true, "mod": true}
+var registerComparisons = map[string]bool{"<": true, "<=": true, ">": true, ">=": true, "==": true, "!=": true}
+
+// runs of comparison characters stay together, so a typo like `<>` is
+// reported as a bad comparison rather than as two good ones.
+var registerTokenRe = regexp.MustCompile(`^\s*(\d+|[A-Za-z_]\w*|&&|\|\||[<>=]+|!=|[-+*()!])`)
+
+func tokenizeRegisterInstruction(instruction string) ([]string, error) {
+	tokens := []string{}
+	rest := strings.TrimRight(instruction, " \t")
+	for len(rest) > 0 {
+		matches := registerTokenRe.FindStringSubmatch(rest)
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("error: could not parse instruction `%s`: unexpected `%s`", instruction, strings.TrimSpace(rest)[:1])
+		}
+		tokens = append(tokens, matches[1])
+		rest = rest[len(matches[0]):]
+	}
+	return tokens, nil
+}
+
+type registerExpr interface {
+	eval(rs RegisterSet) int
+	precedence() int // binds tighter as it grows
+	String() string
+}
+
+type registerLiteral int
+type registerRef string
+
+type registerBinary struct {
+	op          string // +, - or *
+	left, right registerExpr
+}
+
+type registerNeg struct {
+	operand registerExpr
+}
+
+func (e registerLiteral) eval(rs RegisterSet) int { return int(e) }
+func (e registerRef) eval(rs RegisterSet) int     { return rs[string(e)] }
+func (e registerNeg) eval(rs RegisterSet) int     { return -e.operand.eval(rs) }
+
+func (e registerBinary) eval(rs RegisterSet) int {
+	left, right := e.left.eval(rs), e.right.eval(rs)
+	switch e.op {
+	case "+":
+		return left + right
+	case "-":
+		return left - right
+	}
+	return left * right
+}
+
+func (e registerLiteral) precedence() int { return 3 }
+func (e registerRef) precedence() int     { return 3 }
+func (e registerNeg) precedence() int     { return 3 }
+
+func (e registerBinary) precedence() int {
+	if e.op == "*" {
+		return 2
+	}
+	return 1
+}
+
+func (e registerLiteral) String() string { return strconv.Itoa(int(e)) }
+func (e registerRef) String() string     { return string(e) }
+
+func (e registerNeg) String() string {
+	return "-" + parenthesize(e.operand, e.operand.precedence() < 3)
+}
+
+func (e registerBinary) String() string {
+	left := parenthesize(e.left, e.left.precedence() < e.precedence())
+	// subtraction doesn't associate, so a - (b - c) keeps its parentheses
+	right := parenthesize(e.right, e.right.precedence() < e.precedence() || e.right.precedence() == e.precedence() && e.op == "-")
+	return fmt.Sprintf("%s %s %s", left, e.op, right)
+}
+
+func parenthesize(thing fmt.Stringer, needed bool) string {
+	if needed {
+		return "(" + thing.String() + ")"
+	}
+	return thing.String()
+}
+
+type registerCond interface {
+	holds(rs RegisterSet) bool
+	String() string
+}
+
+type registerComparison struct {
+	op          string
+	left, right registerExpr
+}
+
+type registerLogical struct {
+	op          string // && or ||
+	left, right registerCond
+}
+
+type registerNot struct {
+	operand registerCond
+}
+
+func (c registerComparison) holds(rs RegisterSet) bool {
+	left, right := c.left.eval(rs), c.right.eval(rs)
+	switch c.op {
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	case ">=":
+		return left >= right
+	case "==":
+		return left == right
+	}
+	return left != right
+}
+
+func (c registerLogical) holds(rs RegisterSet) bool {
+	if c.op == "&&" {
+		return c.left.holds(rs) && c.right.holds(rs)
+	}
+	return c.left.holds(rs) || c.right.holds(rs)
+}
+
+func (c registerNot) holds(rs RegisterSet) bool {
+	return !c.operand.holds(rs)
+}
+
+func (c registerComparison) String() string {
+	return fmt.Sprintf("%s %s %s", c.left, c.op, c.right)
+}
+
+func (c registerLogical) String() string {
+	render := func(operand registerCond) string {
+		inner, ok := operand.(registerLogical)
+		return parenthesize(operand, ok && inner.op == "||" && c.op == "&&")
+	}
+	return fmt.Sprintf("%s %s %s", render(c.left), c.op, render(c.right))
+}
+
+func (c registerNot) String() string {
+	_, ok := c.operand.(registerNot)
+	return "!" + parenthesize(c.operand, !ok)
+}
+
+// the first error sticks, and every later call is a no-op.
+type registerParser struct {
+	instruction string
+	tokens      []string
+	pos         int
+	refs        []string // registers referenced, in order of appearance
+	err         error
+}
+
+func (p *registerParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *registerParser) next() string {
+	token := p.peek()
+	if p.err == nil && p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return token
+}
+
+func (p *registerParser) accept(token string) bool {
+	if p.err == nil && p.peek() == token {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *registerParser) fail(format string, args ...interface{}) {
+	if p.err == nil {
+		p.err = fmt.Errorf("error: could not parse instruction `%s`: %s", p.instruction, fmt.Sprintf(format, args...))
+	}
+}
+
+func (p *registerParser) expect(token string) {
+	if !p.accept(token) {
+		p.fail("expected `%s`, got `%s`", token, p.peek())
+	}
+}
+
+func isRegisterName(token string) bool {
+	return len(token) > 0 && (token[0] == '_' || 'A' <= token[0] && token[0] <= 'Z' || 'a' <= token[0] && token[0] <= 'z') && token != "if"
+}
+
+func (p *registerParser) register() string {
+	token := p.next()
+	if !isRegisterName(token) {
+		p.fail("expected a register, got `%s`", token)
+		return ""
+	}
+	p.refs = append(p.refs, token)
+	return token
+}
+
+func (p *registerParser) cond() registerCond {
+	left := p.and()
+	for p.accept("||") {
+		left = registerLogical{op: "||", left: left, right: p.and()}
+	}
+	return left
+}
+
+func (p *registerParser) and() registerCond {
+	left := p.not()
+	for p.accept("&&") {
+		left = registerLogical{op: "&&", left: left, right: p.not()}
+	}
+	return left
+}
+
+func (p *registerParser) not() registerCond {
+	if p.accept("!") {
+		return registerNot{operand: p.not()}
+	}
+	if p.peek() == "(" {
+		// either a parenthesized condition or an expression starting a comparison
+		pos, refs, err := p.pos, len(p.refs), p.err
+		p.next()
+		cond := p.cond()
+		if p.err == nil && p.accept(")") && !registerComparisons[p.peek()] {
+			return cond
+		}
+		p.pos, p.refs, p.err = pos, p.refs[:refs], err
+	}
+	return p.comparison()
+}
+
+func (p *registerParser) comparison() registerCond {
+	left := p.expr()
+	op := p.next()
+	switch {
+	case registerComparisons[op]:
+	case op != "" && strings.Trim(op, "<>=!") == "" && p.err == nil:
+		p.err = fmt.Errorf("error: unrecognized comparison `%s`", op)
+	default:
+		p.fail("expected a comparison, got `%s`", op)
+	}
+	return registerComparison{op: op, left: left, right: p.expr()}
+}
+
+func (p *registerParser) expr() registerExpr {
+	left := p.term()
+	for p.err == nil && (p.peek() == "+" || p.peek() == "-") {
+		left = registerBinary{op: p.next(), left: left, right: p.term()}
+	}
+	return left
+}
+
+func (p *registerParser) term() registerExpr {
+	left := p.factor()
+	for p.accept("*") {
+		left = registerBinary{op: "*", left: left, right: p.factor()}
+	}
+	return left
+}
+
+func (p *registerParser) factor() registerExpr {
+	switch token := p.peek(); {
+	case p.accept("-"):
+		operand := p.factor()
+		if literal, ok := operand.(registerLiteral); ok {
+			return -literal
+		}
+		return registerNeg{operand: operand}
+	case p.accept("("):
+		e := p.expr()
+		p.expect(")")
+		return e
+	case len(token) > 0 && '0' <= token[0] && token[0] <= '9':
+		p.next()
+		value, err := strconv.Atoi(token)
+		if err != nil && p.err == nil {
+			p.err = fmt.Errorf("error: cannot parse `%s` as an int", token)
+		}
+		return registerLiteral(value)
+	}
+	return registerRef(p.register())
+}
+
+func parseRegisterInstruction(instruction string) (registerInstruction, error) {
+	tokens, err := tokenizeRegisterInstruction(instruction)
+	if err != nil {
+		return registerInstruction{}, err
+	}
+	p := registerParser{instruction: instruction, tokens: tokens}
+
+	ri := registerInstruction{register: p.register(), operator: p.next()}
+	if p.err == nil && !registerOperators[ri.operator] {
+		if ri.operator == "" {
+			p.fail("missing operator")
+		} else {
+			p.err = fmt.Errorf("error: unrecognized operator `%s`", ri.operator)
+		}
+	}
+	ri.value = p.expr()
+	p.expect("if")
+	ri.cond = p.cond()
+	if p.err == nil && p.pos < len(p.tokens) {
+		p.fail("unexpected `%s`", p.peek())
+	}
+	ri.refs = p.refs
+	return ri, p.err
+}
+
+var _ = Describe("Day8", func() {
+	Describe("parseRegisterInstruction", func() {
+		var rs RegisterSet
+
+		BeforeEach(func() {
+			rs = RegisterSet{"a": 3, "b": 5, "c": -2}
+		})
+
+		It("compares registers to registers", func() {
+			rs.execInstruction("d inc 1 if a < b")
+			rs.execInstruction("e inc 1 if a > b")
+			Expect(rs["d"]).To(Equal(1))
+			Expect(rs["e"]).To(Equal(0))
+		})
+
+		It("combines conditions", func() {
+			rs.execInstruction("d inc 1 if a < b && c < 0")
+			rs.execInstruction("e inc 1 if a > b || c < 0")
+			rs.execInstruction("f inc 1 if !(a < b) || !c == -2")
+			rs.execInstruction("g inc 1 if !(a > b || c > 0) && b == 5")
+			Expect(rs["d"]).To(Equal(1))
+			Expect(rs["e"]).To(Equal(1))
+			Expect(rs["f"]).To(Equal(0))
+			Expect(rs["g"]).To(Equal(1))
+		})
+
+		It("accepts arithmetic operands", func() {
+			rs.execInstruction("a inc b*2 if c > -5")
+			Expect(rs["a"]).To(Equal(13))
+			rs.execInstruction("a dec (b - c) * 2 + -1 if (a + c) * 2 > 20")
+			Expect(rs["a"]).To(Equal(0))
+		})
+
+		It("supports mul, set and mod", func() {
+			rs.execInstruction("a mul b if b > 0")
+			Expect(rs["a"]).To(Equal(15))
+			rs.execInstruction("a mod 4 if b > 0")
+			Expect(rs["a"]).To(Equal(3))
+			rs.execInstruction("a set c - 1 if b > 0")
+			Expect(rs["a"]).To(Equal(-3))
+		})
+
+		It("creates every register referenced", func() {
+			rs.execInstruction("d inc e if f > g && h == 0")
+			for _, name := range []string{"d", "e", "f", "g", "h"} {
+				Expect(rs).To(HaveKey(name))
+			}
+		})
+
+		It("renders instructions canonically", func() {
+			for raw, canonical := range map[string]string{
+				"a inc 1 if b < 5":                        "a inc 1 if b < 5",
+				"a inc b*2 if c>d":                        "a inc b * 2 if c > d",
+				"a dec (b-c)*2 if !(a<b)&&(c<0||d<0)":     "a dec (b - c) * 2 if !(a < b) && (c < 0 || d < 0)",
+				"a set a-(b-c) if ((a == 1))":             "a set a - (b - c) if a == 1",
+				"a mul -(b+1) if (a + 1) * 2 == -b":       "a mul -(b + 1) if (a + 1) * 2 == -b",
+				"a mod 3 if a > 0 || b > 0 && c > 0":      "a mod 3 if a > 0 || b > 0 && c > 0",
+				"a inc 1 if (a > 0 || b > 0) && c > 0":    "a inc 1 if (a > 0 || b > 0) && c > 0",
+				"a inc 1 if !!(a > 0)":                    "a inc 1 if !!(a > 0)",
+				"a inc 1 - 2 - 3 if 1 - (2 - 3) == 2 * 1": "a inc 1 - 2 - 3 if 1 - (2 - 3) == 2 * 1",
+			} {
+				ri, err := parseRegisterInstruction(raw)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ri.String()).To(Equal(canonical))
+
+				reparsed, _ := parseRegisterInstruction(ri.String())
+				Expect(reparsed.String()).To(Equal(canonical))
+			}
+		})
+
+		It("reports malformed instructions", func() {
+			for raw, message := range map[string]string{
+				"a foo 1 if b < 5":                    "error: unrecognized operator `foo`",
+				"a inc 1 b < 5":                       "error: could not parse instruction `a inc 1 b < 5`: expected `if`, got `b`",
+				"a inc 1 if b <> 5":                   "error: unrecognized comparison `<>`",
+				"a inc 1 if b":                        "error: could not parse instruction `a inc 1 if b`: expected a comparison, got ``",
+				"a inc (1 if b < 5":                   "error: could not parse instruction `a inc (1 if b < 5`: expected `)`, got `if`",
+				"a inc 1 if b < 5 )":                  "error: could not parse instruction `a inc 1 if b < 5 )`: unexpected `)`",
+				"a inc 1 if b < 5;":                   "error: could not parse instruction `a inc 1 if b < 5;`: unexpected `;`",
+				"a inc 99999999999999999999 if b < 5": "error: cannot parse `99999999999999999999` as an int",
+			} {
+				_, err := parseRegisterInstruction(raw)
+				Expect(err).To(MatchError(message), raw)
+			}
+		})
+	})
+})
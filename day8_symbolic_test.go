@@ -0,0 +1,340 @@
+package adventofcode2017_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	symbolicMin = math.MinInt64 // unbounded below
+	symbolicMax = math.MaxInt64 // unbounded above
+)
+
+type SymbolicInterval struct {
+	lo, hi int // inclusive
+}
+
+func (iv SymbolicInterval) empty() bool {
+	return iv.lo > iv.hi
+}
+
+func (iv SymbolicInterval) intersect(other SymbolicInterval) SymbolicInterval {
+	if other.lo > iv.lo {
+		iv.lo = other.lo
+	}
+	if other.hi < iv.hi {
+		iv.hi = other.hi
+	}
+	return iv
+}
+
+// the values v for which `v predicate operand` holds.
+func predicateIntervals(predicate string, operand int) []SymbolicInterval {
+	switch predicate {
+	case "<":
+		return []SymbolicInterval{{symbolicMin, operand - 1}}
+	case "<=":
+		return []SymbolicInterval{{symbolicMin, operand}}
+	case ">":
+		return []SymbolicInterval{{operand + 1, symbolicMax}}
+	case ">=":
+		return []SymbolicInterval{{operand, symbolicMax}}
+	case "==":
+		return []SymbolicInterval{{operand, operand}}
+	case "!=":
+		return []SymbolicInterval{{symbolicMin, operand - 1}, {operand + 1, symbolicMax}}
+	}
+	panic(fmt.Sprintf("error: unrecognized operator `%s`", predicate))
+}
+
+var negatedPredicates = map[string]string{"<": ">=", "<=": ">", ">": "<=", ">=": "<", "==": "!=", "!=": "=="}
+
+// a path through the program. every register's value is its unknown
+// initial value plus an offset, and the initial values are confined to
+// a box.
+type SymbolicPath struct {
+	bounds   map[string]SymbolicInterval // register → possible initial values
+	offsets  map[string]int              // register → value minus initial value
+	unknowns map[string]bool             // shared with the SymbolicRegisterSet
+}
+
+func (p SymbolicPath) fork() SymbolicPath {
+	rval := SymbolicPath{bounds: make(map[string]SymbolicInterval, len(p.bounds)), offsets: make(map[string]int, len(p.offsets)), unknowns: p.unknowns}
+	for name, bound := range p.bounds {
+		rval.bounds[name] = bound
+	}
+	for name, offset := range p.offsets {
+		rval.offsets[name] = offset
+	}
+	return rval
+}
+
+// the paths on which `register predicate operand` holds at this point.
+func (p SymbolicPath) constrain(register, predicate string, operand int) []SymbolicPath {
+	rval := []SymbolicPath{}
+	for _, iv := range predicateIntervals(predicate, operand-p.offsets[register]) {
+		bound := p.bounds[register].intersect(iv)
+		if bound.empty() {
+			continue
+		}
+		forked := p.fork()
+		forked.bounds[register] = bound
+		rval = append(rval, forked)
+	}
+	return rval
+}
+
+// describes the box of unknowns as a conjunction, or "always" if it is
+// unconstrained.
+func (p SymbolicPath) condition() string {
+	names := make([]string, 0, len(p.unknowns))
+	for name := range p.unknowns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	terms := []string{}
+	for _, name := range names {
+		bound := p.bounds[name]
+		switch {
+		case bound.lo == symbolicMin && bound.hi == symbolicMax:
+			continue
+		case bound.lo == bound.hi:
+			terms = append(terms, fmt.Sprintf("%s == %d", name, bound.lo))
+		case bound.lo == symbolicMin:
+			terms = append(terms, fmt.Sprintf("%s <= %d", name, bound.hi))
+		case bound.hi == symbolicMax:
+			terms = append(terms, fmt.Sprintf("%s >= %d", name, bound.lo))
+		default:
+			terms = append(terms, fmt.Sprintf("%d <= %s <= %d", bound.lo, name, bound.hi))
+		}
+	}
+	if len(terms) == 0 {
+		return "always"
+	}
+	return strings.Join(terms, " && ")
+}
+
+// a register's value on this path, in terms of its initial value.
+func (p SymbolicPath) expression(register string) string {
+	bound, offset := p.bounds[register], p.offsets[register]
+	switch {
+	case bound.lo == bound.hi:
+		return fmt.Sprintf("%d", bound.lo+offset)
+	case offset > 0:
+		return fmt.Sprintf("%s + %d", register, offset)
+	case offset < 0:
+		return fmt.Sprintf("%s - %d", register, -offset)
+	}
+	return register
+}
+
+type SymbolicRegisterSet struct {
+	unknowns  map[string]bool // registers whose initial value is unknown; the rest start at zero
+	paths     []SymbolicPath
+	live      map[int]bool // instruction → executed on some path
+	executed  int          // instructions seen so far
+	maxPaths  int
+	registers map[string]bool
+}
+
+func NewSymbolicRegisterSet(unknowns []string, maxPaths int) *SymbolicRegisterSet {
+	srs := SymbolicRegisterSet{
+		unknowns:  make(map[string]bool),
+		live:      make(map[int]bool),
+		maxPaths:  maxPaths,
+		registers: make(map[string]bool),
+	}
+	srs.paths = []SymbolicPath{SymbolicPath{bounds: make(map[string]SymbolicInterval), offsets: make(map[string]int), unknowns: srs.unknowns}}
+	for _, name := range unknowns {
+		srs.unknowns[name] = true
+		srs.ensureRegister(name)
+	}
+	return &srs
+}
+
+func (srs *SymbolicRegisterSet) ensureRegister(registerName string) {
+	if srs.registers[registerName] {
+		return
+	}
+	srs.registers[registerName] = true
+	bound := SymbolicInterval{0, 0}
+	if srs.unknowns[registerName] {
+		bound = SymbolicInterval{symbolicMin, symbolicMax}
+	}
+	for _, p := range srs.paths {
+		p.bounds[registerName] = bound
+	}
+}
+
+// splits every path on the instruction's predicate. an error means the
+// number of paths would exceed maxPaths, and leaves the state untouched.
+func (srs *SymbolicRegisterSet) execInstruction(instruction string) error {
+	ri, err := parseRegisterInstruction(instruction)
+	if err != nil {
+		return err
+	}
+	delta, predSubject, predicate, predOperand, ok := ri.linear()
+	if !ok {
+		return fmt.Errorf("error: symbolic execution needs the form `reg inc|dec N if reg OP N`, got `%s`", instruction)
+	}
+	srs.ensureRegister(ri.register)
+	srs.ensureRegister(predSubject)
+
+	paths := []SymbolicPath{}
+	taken := false
+	for _, p := range srs.paths {
+		for _, q := range p.constrain(predSubject, predicate, predOperand) {
+			q.offsets[ri.register] += delta
+			paths = append(paths, q)
+			taken = true
+		}
+		paths = append(paths, p.constrain(predSubject, negatedPredicates[predicate], predOperand)...)
+	}
+	if len(paths) > srs.maxPaths {
+		return fmt.Errorf("error: instruction %d `%s` needs %d paths, limit is %d", srs.executed, instruction, len(paths), srs.maxPaths)
+	}
+
+	srs.paths = paths
+	if taken {
+		srs.live[srs.executed] = true
+	}
+	srs.executed++
+	return nil
+}
+
+// instructions whose condition can never hold, by position.
+func (srs *SymbolicRegisterSet) deadInstructions() []int {
+	rval := []int{}
+	for j := 0; j < srs.executed; j++ {
+		if !srs.live[j] {
+			rval = append(rval, j)
+		}
+	}
+	return rval
+}
+
+// the boxes of initial values for which `register predicate operand`
+// holds at the end of the program.
+func (srs *SymbolicRegisterSet) query(register, predicate string, operand int) []SymbolicPath {
+	srs.ensureRegister(register)
+	rval := []SymbolicPath{}
+	for _, p := range srs.paths {
+		rval = append(rval, p.constrain(register, predicate, operand)...)
+	}
+	return rval
+}
+
+// renders a register's final value as one "value if condition" line
+// per path.
+func (srs *SymbolicRegisterSet) piecewise(register string) string {
+	srs.ensureRegister(register)
+	var out bytes.Buffer
+	for _, p := range srs.paths {
+		fmt.Fprintf(&out, "%s if %s\n", p.expression(register), p.condition())
+	}
+	return out.String()
+}
+
+var _ = Describe("Day8", func() {
+	Describe("SymbolicRegisterSet", func() {
+		instructions := strings.Split(heredoc.Doc(`
+			a inc 5 if b > 3
+			a inc 10 if b > 6
+			c dec 1 if a > 100
+			b dec 2 if a == 0
+		`), "\n")
+
+		run := func(unknowns []string, maxPaths int) (*SymbolicRegisterSet, error) {
+			srs := NewSymbolicRegisterSet(unknowns, maxPaths)
+			for _, instruction := range instructions {
+				if len(instruction) > 0 {
+					if err := srs.execInstruction(instruction); err != nil {
+						return srs, err
+					}
+				}
+			}
+			return srs, nil
+		}
+
+		It("ends with each register as a piecewise expression", func() {
+			srs, err := run([]string{"b"}, 100)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(srs.piecewise("a")).To(Equal("15 if b >= 7\n5 if 4 <= b <= 6\n0 if b <= 3\n"))
+			Expect(srs.piecewise("b")).To(Equal("b if b >= 7\nb if 4 <= b <= 6\nb - 2 if b <= 3\n"))
+			Expect(srs.piecewise("c")).To(Equal("0 if b >= 7\n0 if 4 <= b <= 6\n0 if b <= 3\n"))
+		})
+
+		It("answers questions about the final state", func() {
+			srs, _ := run([]string{"b"}, 100)
+
+			boxes := srs.query("a", ">", 10)
+			Expect(boxes).To(HaveLen(1))
+			Expect(boxes[0].condition()).To(Equal("b >= 7"))
+
+			boxes = srs.query("b", "<", 0)
+			Expect(boxes).To(HaveLen(1))
+			Expect(boxes[0].condition()).To(Equal("b <= 1"))
+		})
+
+		It("finds dead instructions", func() {
+			srs, _ := run([]string{"b"}, 100)
+			Expect(srs.deadInstructions()).To(Equal([]int{2}))
+		})
+
+		It("treats more unknowns as more freedom", func() {
+			srs, _ := run([]string{"a", "b"}, 100)
+			Expect(srs.deadInstructions()).To(BeEmpty())
+			Expect(srs.query("c", "<", 0)[0].condition()).To(Equal("a >= 86 && b >= 7"))
+		})
+
+		It("executes concretely without unknowns", func() {
+			srs, _ := run(nil, 1)
+			Expect(srs.piecewise("a")).To(Equal("0 if always\n"))
+			Expect(srs.piecewise("b")).To(Equal("-2 if always\n"))
+			Expect(srs.deadInstructions()).To(Equal([]int{0, 1, 2}))
+		})
+
+		It("rejects instructions outside the linear form", func() {
+			srs := NewSymbolicRegisterSet([]string{"b"}, 100)
+			for _, instruction := range []string{"a inc b if b > 0", "a mul 2 if b > 0", "a inc 1 if b > c", "a inc 1 if b > 0 && b < 5"} {
+				Expect(srs.execInstruction(instruction)).To(MatchError(fmt.Sprintf("error: symbolic execution needs the form `reg inc|dec N if reg OP N`, got `%s`", instruction)))
+			}
+			Expect(srs.executed).To(Equal(0))
+		})
+
+		It("gives up beyond the path limit", func() {
+			srs, err := run([]string{"b"}, 2)
+			Expect(err).To(MatchError("error: instruction 1 `a inc 10 if b > 6` needs 3 paths, limit is 2"))
+			Expect(srs.paths).To(HaveLen(2))
+		})
+	})
+
+	Describe("puzzle", func() {
+		rawData, _ := ioutil.ReadFile("day8.txt")
+		instructions := strings.Split(string(rawData), "\n")
+
+		It("agrees with concrete execution when nothing is unknown", func() {
+			rs := NewRegisterSet()
+			srs := NewSymbolicRegisterSet(nil, 1)
+			for _, instruction := range instructions {
+				if len(instruction) > 0 {
+					rs.execInstruction(instruction)
+					Expect(srs.execInstruction(instruction)).To(Succeed())
+				}
+			}
+			for name, value := range rs {
+				Expect(srs.paths[0].expression(name)).To(Equal(fmt.Sprintf("%d", value)))
+			}
+			fmt.Printf("d8 symbolic: %d of %d instructions never execute\n", len(srs.deadInstructions()), srs.executed)
+		})
+	})
+})
@@ -3,16 +3,13 @@ package adventofcode2017_test
 import (
 	"fmt"
 	"io/ioutil"
-	"regexp"
-	"strconv"
+	"sort"
 	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
 
-var instructionRe = regexp.MustCompile(`^(\w+) (\w+) ([-\w]+) if (\w+) (.*) ([-\w]+)$`)
-
 type RegisterSet map[string]int // register name → register value
 
 func NewRegisterSet() RegisterSet {
@@ -27,77 +24,166 @@ func (rs RegisterSet) ensureRegister(registerName string) {
 	}
 }
 
-func (rs RegisterSet) execInstruction(instruction string) {
-	matches := instructionRe.FindStringSubmatch(instruction)
-	if len(matches) == 0 {
-		panic(fmt.Sprintf("error: could not parse instruction `%s`", instruction))
+type registerInstruction struct {
+	register string
+	operator string // inc, dec, mul, set or mod
+	value    registerExpr
+	cond     registerCond
+	refs     []string // every register the instruction mentions
+}
+
+func (ri registerInstruction) String() string {
+	return fmt.Sprintf("%s %s %s if %s", ri.register, ri.operator, ri.value, ri.cond)
+}
+
+// picks apart the original `reg inc|dec N if reg OP N` form.
+func (ri registerInstruction) linear() (delta int, predSubject, predicate string, predOperand int, ok bool) {
+	value, ok1 := ri.value.(registerLiteral)
+	cond, ok2 := ri.cond.(registerComparison)
+	if !ok1 || !ok2 || (ri.operator != "inc" && ri.operator != "dec") {
+		return 0, "", "", 0, false
+	}
+	subject, ok3 := cond.left.(registerRef)
+	operand, ok4 := cond.right.(registerLiteral)
+	if !ok3 || !ok4 {
+		return 0, "", "", 0, false
 	}
 
-	registerName := matches[1]
-	operator := matches[2]
-	operandStr := matches[3]
-	predSubject := matches[4]
-	predicate := matches[5]
-	predOperandStr := matches[6]
+	delta = int(value)
+	if ri.operator == "dec" {
+		delta = -delta
+	}
+	return delta, string(subject), cond.op, int(operand), true
+}
 
-	operand, err := strconv.Atoi(operandStr)
+func (rs RegisterSet) execInstruction(instruction string) {
+	ri, err := parseRegisterInstruction(instruction)
 	if err != nil {
-		panic(fmt.Sprintf("error: cannot parse `%s` as an int", operandStr))
+		panic(err.Error())
+	}
+	if _, err = rs.exec(ri); err != nil {
+		panic(err.Error())
 	}
+}
 
-	predOperand, err := strconv.Atoi(predOperandStr)
-	if err != nil {
-		panic(fmt.Sprintf("error: cannot parse `%s` as an int", predOperandStr))
+// returns whether the condition held. on error no register value
+// changes, though every register the instruction mentions will have been
+// created at zero.
+func (rs RegisterSet) exec(ri registerInstruction) (bool, error) {
+	for _, name := range ri.refs {
+		rs.ensureRegister(name)
 	}
 
-	rs.ensureRegister(registerName)
-	rs.ensureRegister(predSubject)
+	// test the condition
+	if !ri.cond.holds(rs) {
+		return false, nil
+	}
 
-	// test the predicate
-	value := rs[predSubject]
-	predVal := false
-	switch predicate {
-	case "<":
-		if value < predOperand {
-			predVal = true
-		}
-	case "<=":
-		if value <= predOperand {
-			predVal = true
+	// execute the instruction
+	value := ri.value.eval(rs)
+	switch ri.operator {
+	case "inc":
+		rs[ri.register] += value
+	case "dec":
+		rs[ri.register] -= value
+	case "mul":
+		rs[ri.register] *= value
+	case "set":
+		rs[ri.register] = value
+	case "mod":
+		if value == 0 {
+			return false, fmt.Errorf("error: mod by zero in `%s`", ri)
 		}
-	case ">":
-		if value > predOperand {
-			predVal = true
+		rs[ri.register] %= value
+	}
+	return true, nil
+}
+
+type RegisterProgram []registerInstruction
+
+// blank lines, including those that are only whitespace, are skipped, as
+// runReader does.
+func parseRegisterProgram(raw string) (RegisterProgram, error) {
+	program := RegisterProgram{}
+	for _, line := range strings.Split(raw, "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
 		}
-	case ">=":
-		if value >= predOperand {
-			predVal = true
+		ri, err := parseRegisterInstruction(line)
+		if err != nil {
+			return nil, err
 		}
-	case "==":
-		if value == predOperand {
-			predVal = true
+		program = append(program, ri)
+	}
+	return program, nil
+}
+
+type RegisterChange struct {
+	instruction int // index into the program
+	register    string
+	value       int // the value after the change
+}
+
+type RegisterHistory struct {
+	initial map[string]int   // register values when the run started
+	changes []RegisterChange // in program order
+}
+
+// runs the program, recording every value written to a register.
+func (rs RegisterSet) run(program RegisterProgram) RegisterHistory {
+	history := RegisterHistory{initial: make(map[string]int, len(rs)), changes: []RegisterChange{}}
+	for register, value := range rs {
+		history.initial[register] = value
+	}
+	for j, ri := range program {
+		executed, err := rs.exec(ri)
+		if err != nil {
+			panic(err.Error())
 		}
-	case "!=":
-		if value != predOperand {
-			predVal = true
+		if executed {
+			history.changes = append(history.changes, RegisterChange{instruction: j, register: ri.register, value: rs[ri.register]})
 		}
-	default:
-		panic(fmt.Sprintf("error: unrecognized operator `%s`", predicate))
 	}
-	if !predVal {
-		return
+	return history
+}
+
+// the values a register held over time, starting from its value when
+// the run started.
+func (h RegisterHistory) of(register string) []int {
+	rval := []int{h.initial[register]}
+	for _, change := range h.changes {
+		if change.register == register {
+			rval = append(rval, change.value)
+		}
 	}
+	return rval
+}
 
-	// execute the instruction
-	switch operator {
-	case "inc":
-		rs[registerName] += operand
-	case "dec":
-		rs[registerName] -= operand
-	default:
-		panic(fmt.Sprintf("error: unrecognized operator `%s`", operator))
+// the earliest change to the highest value ever held. a register's value
+// before the run counts as a change at instruction -1, before
+// instruction 0. false if no register held a value.
+func (h RegisterHistory) peak() (RegisterChange, bool) {
+	registers := []string{}
+	for register := range h.initial {
+		registers = append(registers, register)
 	}
+	sort.Strings(registers)
+	changes := []RegisterChange{}
+	for _, register := range registers {
+		changes = append(changes, RegisterChange{instruction: -1, register: register, value: h.initial[register]})
+	}
+	changes = append(changes, h.changes...)
 
+	if len(changes) == 0 {
+		return RegisterChange{}, false
+	}
+	peak := changes[0]
+	for _, change := range changes[1:] {
+		if change.value > peak.value {
+			peak = change
+		}
+	}
+	return peak, true
 }
 
 var _ = Describe("Day8", func() {
@@ -216,6 +302,79 @@ var _ = Describe("Day8", func() {
 				Expect(rs["a"]).To(Equal(1))
 			})
 		})
+
+		Describe("run", func() {
+			var program RegisterProgram
+
+			BeforeEach(func() {
+				var err error
+				program, err = parseRegisterProgram("b inc 5 if a > 1\na inc 1 if b < 5\nc dec -10 if a >= 1\nc inc -20 if c == 10\n")
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("parses a program once", func() {
+				Expect(program).To(HaveLen(4))
+				Expect(program[2].String()).To(Equal("c dec -10 if a >= 1"))
+			})
+
+			It("skips lines that are only whitespace", func() {
+				program, err := parseRegisterProgram("a inc 1 if b < 5\n  \t\n\nb inc 2 if a > 0\n")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(program).To(HaveLen(2))
+			})
+
+			It("creates the registers an instruction mentions even when it fails", func() {
+				ri, err := parseRegisterInstruction("c mod b if a > -1")
+				Expect(err).NotTo(HaveOccurred())
+				_, err = rs.exec(ri)
+				Expect(err).To(MatchError("error: mod by zero in `c mod b if a > -1`"))
+				Expect(rs).To(Equal(RegisterSet{"a": 0, "b": 0, "c": 0}))
+			})
+
+			It("reports parse errors", func() {
+				_, err := parseRegisterProgram("a inc 1 if b < 5\na foo 1 if b < 5\n")
+				Expect(err).To(MatchError("error: unrecognized operator `foo`"))
+			})
+
+			It("runs to the same result as execInstruction", func() {
+				rs.run(program)
+				Expect(rs).To(Equal(RegisterSet{"a": 1, "b": 0, "c": -10}))
+			})
+
+			It("records the history of every register", func() {
+				history := rs.run(program)
+				Expect(history.changes).To(Equal([]RegisterChange{
+					RegisterChange{instruction: 1, register: "a", value: 1},
+					RegisterChange{instruction: 2, register: "c", value: 10},
+					RegisterChange{instruction: 3, register: "c", value: -10},
+				}))
+				Expect(history.of("c")).To(Equal([]int{0, 10, -10}))
+				Expect(history.of("b")).To(Equal([]int{0}))
+			})
+
+			It("starts each register's history from its value before the run", func() {
+				rs["c"] = 5
+				history := rs.run(program)
+				Expect(history.of("c")).To(Equal([]int{5, 15}))
+				Expect(history.of("a")).To(Equal([]int{0, 1}))
+			})
+
+			It("reports the highest value ever held", func() {
+				peak, ok := rs.run(program).peak()
+				Expect(ok).To(BeTrue())
+				Expect(peak).To(Equal(RegisterChange{instruction: 2, register: "c", value: 10}))
+
+				_, ok = NewRegisterSet().run(RegisterProgram{}).peak()
+				Expect(ok).To(BeFalse())
+			})
+
+			It("counts values held before the run towards the highest", func() {
+				rs["b"] = 50
+				peak, ok := rs.run(program).peak()
+				Expect(ok).To(BeTrue())
+				Expect(peak).To(Equal(RegisterChange{instruction: -1, register: "b", value: 50}))
+			})
+		})
 	})
 
 	Describe("puzzle", func() {
@@ -254,5 +413,11 @@ var _ = Describe("Day8", func() {
 			}
 			fmt.Printf("d8 s2: largest transient value is %d\n", max)
 		})
+
+		It("reports where the largest transient value was written", func() {
+			program, _ := parseRegisterProgram(string(raw_data))
+			peak, _ := NewRegisterSet().run(program).peak()
+			fmt.Printf("d8 s2: largest transient value is %d, in register %s at instruction %d\n", peak.value, peak.register, peak.instruction)
+		})
 	})
 })
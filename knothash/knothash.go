@@ -0,0 +1,134 @@
+// Package knothash implements the knot hash from Advent of Code 2017,
+// Day 10, as a hash.Hash.
+package knothash
+
+import (
+	"fmt"
+	"hash"
+)
+
+// the size of a Day 10 knot hash in bytes.
+const Size = 16
+
+// the knot hash doesn't process its input in blocks; every length is
+// needed in every round, so input is buffered until Sum.
+const BlockSize = 1
+
+// the parameters of a knot hash.
+type Options struct {
+	ListSize  int    // elements in the list, at most 256
+	Rounds    int    // times through the lengths
+	Suffix    []byte // appended to the input to make the lengths
+	BlockSize int    // list elements xored together into each byte of the hash
+}
+
+// the options for the Day 10 hash.
+func DefaultOptions() Options {
+	return Options{ListSize: 256, Rounds: 64, Suffix: []byte{17, 31, 73, 47, 23}, BlockSize: 16}
+}
+
+func (o Options) validate() error {
+	if o.ListSize < 1 || o.ListSize > 256 {
+		return fmt.Errorf("knothash: list size must be between 1 and 256, got %d", o.ListSize)
+	}
+	if o.Rounds < 1 {
+		return fmt.Errorf("knothash: rounds must be positive, got %d", o.Rounds)
+	}
+	if o.BlockSize < 1 {
+		return fmt.Errorf("knothash: block size must be positive, got %d", o.BlockSize)
+	}
+	if o.ListSize%o.BlockSize != 0 {
+		return fmt.Errorf("knothash: list size %d is not divisible by block size %d", o.ListSize, o.BlockSize)
+	}
+	return nil
+}
+
+type digest struct {
+	options Options
+	input   []byte
+}
+
+// a hash.Hash computing the knot hash of everything written to it.
+func New() hash.Hash {
+	return &digest{options: DefaultOptions()}
+}
+
+// like New, but for a variant of the hash. its size is ListSize/BlockSize.
+func NewWithOptions(options Options) (hash.Hash, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	options.Suffix = append([]byte(nil), options.Suffix...)
+	return &digest{options: options}, nil
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	d.input = append(d.input, p...)
+	return len(p), nil
+}
+
+// appends the hash of everything written so far to b, leaving the
+// digest as it was.
+func (d *digest) Sum(b []byte) []byte {
+	return append(b, d.options.sum(d.input)...)
+}
+
+func (d *digest) Reset() {
+	d.input = d.input[:0]
+}
+
+func (d *digest) Size() int {
+	return d.options.ListSize / d.options.BlockSize
+}
+
+func (d *digest) BlockSize() int {
+	return BlockSize
+}
+
+// the knot hash of data.
+func Sum(data []byte) [Size]byte {
+	var rval [Size]byte
+	copy(rval[:], DefaultOptions().sum(data))
+	return rval
+}
+
+func (o Options) sum(data []byte) []byte {
+	lengths := append(append([]byte(nil), data...), o.Suffix...)
+
+	list := make([]byte, o.ListSize)
+	for j := range list {
+		list[j] = byte(j)
+	}
+	position, skip := 0, 0
+	for jround := 0; jround < o.Rounds; jround++ {
+		for _, length := range lengths {
+			// Day 10 calls lengths longer than the list invalid; with
+			// fewer than 256 elements they're possible, and count as
+			// the whole list, both reversing and moving on
+			n := int(length)
+			if n > len(list) {
+				n = len(list)
+			}
+			reverse(list, position, n)
+			position = (position + n + skip) % len(list)
+			skip++
+		}
+	}
+
+	// make dense hash
+	rval := make([]byte, o.ListSize/o.BlockSize)
+	for jblock := range rval {
+		for _, value := range list[jblock*o.BlockSize : (jblock+1)*o.BlockSize] {
+			rval[jblock] ^= value
+		}
+	}
+	return rval
+}
+
+// reverses length elements of the circular list, starting at start.
+// length is at most the length of the list.
+func reverse(list []byte, start, length int) {
+	for j, k := start, start+length-1; j < k; j, k = j+1, k-1 {
+		list[j%len(list)], list[k%len(list)] = list[k%len(list)], list[j%len(list)]
+	}
+}
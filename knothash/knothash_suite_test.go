@@ -0,0 +1,13 @@
+package knothash_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestKnothash(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Knothash Suite")
+}
@@ -0,0 +1,159 @@
+package knothash_test
+
+import (
+	"adventofcode2017/knothash"
+	"encoding/hex"
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("knothash", func() {
+	vectors := map[string]string{
+		"":         "a2582a3a0e66e6e86e3812dcb672a272",
+		"AoC 2017": "33efeb34ea91902bb2f59c9920caa6cd",
+		"1,2,3":    "3efbe78a8d82f29979031a4aa0b16a9d",
+		"1,2,4":    "63960835bcdc130f0b66d7ff4f6a5a8e",
+	}
+
+	Describe("Sum()", func() {
+		It("calculates the Day 10 hashes", func() {
+			for input, expected := range vectors {
+				sum := knothash.Sum([]byte(input))
+				Expect(hex.EncodeToString(sum[:])).To(Equal(expected))
+			}
+		})
+	})
+
+	Describe("New()", func() {
+		It("calculates the Day 10 hashes", func() {
+			for input, expected := range vectors {
+				h := knothash.New()
+				h.Write([]byte(input))
+				Expect(hex.EncodeToString(h.Sum(nil))).To(Equal(expected))
+			}
+		})
+
+		It("hashes everything written, however it was split up", func() {
+			h := knothash.New()
+			_, err := io.Copy(h, strings.NewReader("AoC 2017"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hex.EncodeToString(h.Sum(nil))).To(Equal(vectors["AoC 2017"]))
+
+			h = knothash.New()
+			h.Write([]byte("AoC"))
+			h.Write([]byte(" 20"))
+			h.Write([]byte("17"))
+			Expect(hex.EncodeToString(h.Sum(nil))).To(Equal(vectors["AoC 2017"]))
+		})
+
+		It("gives the same answer when summed twice", func() {
+			h := knothash.New()
+			h.Write([]byte("1,2,3"))
+			Expect(h.Sum(nil)).To(Equal(h.Sum(nil)))
+		})
+
+		It("appends to the slice passed to Sum", func() {
+			h := knothash.New()
+			sum := h.Sum([]byte("prefix"))
+			Expect(string(sum[:6])).To(Equal("prefix"))
+			Expect(hex.EncodeToString(sum[6:])).To(Equal(vectors[""]))
+		})
+
+		It("keeps going after Sum, and starts over after Reset", func() {
+			h := knothash.New()
+			h.Write([]byte("1,2,"))
+			h.Sum(nil)
+			h.Write([]byte("4"))
+			Expect(hex.EncodeToString(h.Sum(nil))).To(Equal(vectors["1,2,4"]))
+
+			h.Reset()
+			h.Write([]byte("1,2,3"))
+			Expect(hex.EncodeToString(h.Sum(nil))).To(Equal(vectors["1,2,3"]))
+		})
+
+		It("reports its sizes", func() {
+			h := knothash.New()
+			Expect(h.Size()).To(Equal(16))
+			Expect(h.Size()).To(Equal(knothash.Size))
+			Expect(h.BlockSize()).To(Equal(knothash.BlockSize))
+		})
+	})
+
+	Describe("NewWithOptions()", func() {
+		It("calculates the Day 10 hashes with the default options", func() {
+			for input, expected := range vectors {
+				h, err := knothash.NewWithOptions(knothash.DefaultOptions())
+				Expect(err).NotTo(HaveOccurred())
+				h.Write([]byte(input))
+				Expect(hex.EncodeToString(h.Sum(nil))).To(Equal(expected))
+			}
+		})
+
+		It("can be made to do a single round of the Day 10 example", func() {
+			h, err := knothash.NewWithOptions(knothash.Options{ListSize: 5, Rounds: 1, BlockSize: 1})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(h.Size()).To(Equal(5))
+			h.Write([]byte{3, 4, 1, 5})
+			Expect(h.Sum(nil)).To(Equal([]byte{3, 4, 2, 1, 0}))
+		})
+
+		It("treats lengths longer than the list as the length of the list", func() {
+			sum := func(lengths ...byte) []byte {
+				h, err := knothash.NewWithOptions(knothash.Options{ListSize: 4, Rounds: 1, BlockSize: 1})
+				Expect(err).NotTo(HaveOccurred())
+				h.Write(lengths)
+				return h.Sum(nil)
+			}
+			Expect(sum(6, 2)).To(Equal([]byte{2, 3, 1, 0}))
+			Expect(sum(6, 2)).To(Equal(sum(4, 2)))
+		})
+
+		It("uses every option", func() {
+			base := knothash.DefaultOptions()
+			sum := func(options knothash.Options) []byte {
+				h, err := knothash.NewWithOptions(options)
+				Expect(err).NotTo(HaveOccurred())
+				h.Write([]byte("AoC 2017"))
+				return h.Sum(nil)
+			}
+
+			variants := []knothash.Options{base, base, base, base}
+			variants[0].ListSize = 128
+			variants[1].Rounds = 63
+			variants[2].Suffix = []byte{17, 31, 73, 47}
+			variants[3].BlockSize = 8
+			for _, variant := range variants {
+				Expect(sum(variant)).NotTo(Equal(sum(base)))
+			}
+			Expect(sum(variants[0])).To(HaveLen(8))
+			Expect(sum(variants[3])).To(HaveLen(32))
+		})
+
+		It("isn't affected by changes to the suffix it was given", func() {
+			options := knothash.DefaultOptions()
+			h, _ := knothash.NewWithOptions(options)
+			options.Suffix[0] = 0
+			Expect(hex.EncodeToString(h.Sum(nil))).To(Equal(vectors[""]))
+		})
+
+		It("rejects options it can't hash with", func() {
+			base := knothash.DefaultOptions()
+			invalid := map[string]func(*knothash.Options){
+				"knothash: list size must be between 1 and 256, got 0":      func(o *knothash.Options) { o.ListSize = 0 },
+				"knothash: list size must be between 1 and 256, got 512":    func(o *knothash.Options) { o.ListSize = 512 },
+				"knothash: rounds must be positive, got 0":                  func(o *knothash.Options) { o.Rounds = 0 },
+				"knothash: block size must be positive, got -1":             func(o *knothash.Options) { o.BlockSize = -1 },
+				"knothash: list size 256 is not divisible by block size 10": func(o *knothash.Options) { o.BlockSize = 10 },
+			}
+			for message, change := range invalid {
+				options := base
+				change(&options)
+				_, err := knothash.NewWithOptions(options)
+				Expect(err).To(MatchError(message))
+			}
+		})
+	})
+})